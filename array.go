@@ -0,0 +1,64 @@
+package firego
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+)
+
+// maxArrayGaps limits how many missing elements are filled with null when
+// an array returned as an object is turned back into an array.
+const maxArrayGaps = 1 << 16
+
+// ValueSlice gets the value of the Firebase reference like Value, for an
+// array. Firebase stores arrays as objects keyed by index and returns them
+// as objects instead of arrays when more than half of the indexes are
+// missing, which cannot be unmarshaled into a slice. ValueSlice turns such
+// objects back into arrays, the missing elements being null so that they
+// are left to their zero value. An object whose keys are not all indexes,
+// or which would need too many missing elements, is kept as an object.
+// Only the value of the reference is converted, not the objects it holds.
+func (fb *Firebase) ValueSlice(v interface{}) error {
+	_, data, err := fb.doRequest(context.Background(), "GET", nil)
+	if err != nil {
+		return err
+	}
+	return fb.unmarshal(arrayJSON(data), v)
+}
+
+// arrayJSON converts a JSON object whose keys are all array indexes to
+// the array it stands for, other documents are returned unchanged.
+func arrayJSON(data []byte) []byte {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil || len(object) == 0 {
+		return data
+	}
+
+	length := 0
+	for key := range object {
+		index, err := strconv.Atoi(key)
+		if err != nil || index < 0 || strconv.Itoa(index) != key {
+			return data
+		}
+		if index >= length {
+			length = index + 1
+		}
+	}
+	if length-len(object) > maxArrayGaps {
+		return data
+	}
+
+	array := make([]json.RawMessage, length)
+	for i := range array {
+		array[i] = json.RawMessage("null")
+	}
+	for key, value := range object {
+		index, _ := strconv.Atoi(key)
+		array[index] = value
+	}
+	converted, err := json.Marshal(array)
+	if err != nil {
+		return data
+	}
+	return converted
+}
@@ -0,0 +1,41 @@
+package firego
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValueSlice(t *testing.T) {
+	t.Parallel()
+	for body, expected := range map[string][]string{
+		`["a","b"]`:          {"a", "b"},
+		`{"0":"a","3":"d"}`:  {"a", "", "", "d"},
+		`{"10":"k","2":"c"}`: {"", "", "c", "", "", "", "", "", "", "", "k"},
+		`null`:               nil,
+		`{"0":"a","1":null}`: {"a", ""},
+	} {
+		server := newTestServer(body)
+		var v []string
+		err := New(server.URL, nil).ValueSlice(&v)
+		server.Close()
+		require.NoError(t, err, body)
+		assert.Equal(t, expected, v, body)
+	}
+}
+
+func TestArrayJSON(t *testing.T) {
+	t.Parallel()
+	for _, data := range []string{
+		`{"a":1,"0":2}`,
+		`{"01":1}`,
+		`{"-1":1}`,
+		`{}`,
+		`"string"`,
+		`{"1000000":1}`,
+	} {
+		assert.Equal(t, data, string(arrayJSON([]byte(data))))
+	}
+	assert.Equal(t, `[null,{"0":1}]`, string(arrayJSON([]byte(`{"1":{"0":1}}`))))
+}
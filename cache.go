@@ -0,0 +1,77 @@
+package firego
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WithCache creates a new Firebase reference whose reads, such as Value, are
+// served from an in-memory cache for ttl after they have been read from
+// Firebase. Any write made through the new reference, such as Set, Update
+// or Remove, empties the cache, but changes made elsewhere, including through
+// other references to the same location, are not seen until the cached value
+// expires.
+//
+// The cache belongs to the new reference alone, it is neither shared with the
+// reference it is created from nor with the references derived from it, such
+// as its children, which are not cached. Reads with options, such as
+// GetWithETag, are never cached.
+func (fb *Firebase) WithCache(ttl time.Duration) *Firebase {
+	c := fb.copy()
+	c.cache = &valueCache{ttl: ttl}
+	return c
+}
+
+// valueCache holds the last value read by a reference.
+type valueCache struct {
+	ttl time.Duration
+
+	mtx     sync.Mutex
+	url     string
+	headers http.Header
+	data    []byte
+	expires time.Time
+	// writes counts the invalidations, so that a value read while
+	// a write was being made is not cached
+	writes uint64
+}
+
+// generation returns the number of invalidations made so far.
+func (c *valueCache) generation() uint64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.writes
+}
+
+// get returns the value cached for the given request url, if it has not expired.
+func (c *valueCache) get(url string) (http.Header, []byte, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.data == nil || c.url != url || !time.Now().Before(c.expires) {
+		return nil, nil, false
+	}
+	return c.headers.Clone(), append([]byte(nil), c.data...), true
+}
+
+// set caches the value read from the given request url, unless the
+// cache has been invalidated since the given generation.
+func (c *valueCache) set(generation uint64, url string, headers http.Header, data []byte) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if generation != c.writes {
+		return
+	}
+	c.url = url
+	c.headers = headers.Clone()
+	c.data = append([]byte(nil), data...)
+	c.expires = time.Now().Add(c.ttl)
+}
+
+// invalidate empties the cache.
+func (c *valueCache) invalidate() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.data = nil
+	c.writes++
+}
@@ -0,0 +1,68 @@
+package firego
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithCache(t *testing.T) {
+	t.Parallel()
+	var (
+		mtx   sync.Mutex
+		value = `1`
+		gets  int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		if req.Method == "GET" {
+			gets++
+		} else {
+			b, _ := ioutil.ReadAll(req.Body)
+			value = string(b)
+		}
+		fmt.Fprint(w, value)
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	cached := fb.WithCache(time.Hour)
+	var v int
+	for i := 0; i < 3; i++ {
+		require.NoError(t, cached.Value(&v))
+		assert.Equal(t, 1, v)
+	}
+	assert.Equal(t, 1, gets)
+
+	// other references, even derived ones, are not cached
+	require.NoError(t, fb.Value(&v))
+	require.NoError(t, cached.Child("a").Value(&v))
+	_, err := cached.ShallowRef().GetRaw()
+	require.NoError(t, err)
+	assert.Equal(t, 4, gets)
+
+	require.NoError(t, cached.Set(2))
+	require.NoError(t, cached.Value(&v))
+	assert.Equal(t, 2, v)
+	require.NoError(t, cached.Value(&v))
+	assert.Equal(t, 5, gets)
+
+	// writes made elsewhere are only seen once the value expires
+	require.NoError(t, fb.Set(3))
+	require.NoError(t, cached.Value(&v))
+	assert.Equal(t, 2, v)
+
+	expiring := fb.WithCache(time.Millisecond)
+	require.NoError(t, expiring.Value(&v))
+	time.Sleep(5 * time.Millisecond)
+	require.NoError(t, expiring.Value(&v))
+	assert.Equal(t, 7, gets)
+}
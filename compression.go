@@ -0,0 +1,46 @@
+package firego
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+)
+
+// SetCompression determines whether requests ask Firebase for gzip
+// compressed responses and whether request bodies are compressed before
+// being sent. By default, no compression is used. Responses are
+// decompressed transparently.
+func (fb *Firebase) SetCompression(v bool) {
+	fb.paramsMtx.Lock()
+	fb.compression = v
+	fb.paramsMtx.Unlock()
+}
+
+func (fb *Firebase) getCompression() bool {
+	fb.paramsMtx.RLock()
+	defer fb.paramsMtx.RUnlock()
+	return fb.compression
+}
+
+// compressBody gzips the given request body and returns the
+// options needed to let Firebase know about it.
+func compressBody(body []byte) ([]byte, []func(*http.Request), error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(body); err != nil {
+		return nil, nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), []func(*http.Request){withHeader("Content-Encoding", "gzip")}, nil
+}
+
+// decompressBody returns a reader for the decoded body of the response.
+func decompressBody(resp *http.Response) (io.Reader, error) {
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		return resp.Body, nil
+	}
+	return gzip.NewReader(resp.Body)
+}
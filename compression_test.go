@@ -0,0 +1,66 @@
+package firego
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompression(t *testing.T) {
+	t.Parallel()
+	var (
+		acceptEncoding  string
+		contentEncoding string
+		received        []byte
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		acceptEncoding = req.Header.Get("Accept-Encoding")
+		contentEncoding = req.Header.Get("Content-Encoding")
+
+		received = nil
+		if contentEncoding == "gzip" {
+			r, err := gzip.NewReader(req.Body)
+			require.NoError(t, err)
+			received, _ = ioutil.ReadAll(r)
+		}
+
+		w.Header().Set("Content-Encoding", "gzip")
+		gw := gzip.NewWriter(w)
+		gw.Write([]byte(`{"foo":"bar"}`))
+		gw.Close()
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	fb.SetCompression(true)
+
+	var v map[string]string
+	require.NoError(t, fb.Value(&v))
+	assert.Equal(t, map[string]string{"foo": "bar"}, v)
+	assert.Equal(t, "gzip", acceptEncoding)
+	assert.Empty(t, contentEncoding)
+
+	require.NoError(t, fb.Child("child").Set(map[string]string{"hello": "world"}))
+	assert.Equal(t, "gzip", contentEncoding)
+	assert.JSONEq(t, `{"hello":"world"}`, string(received))
+}
+
+func TestCompressionDisabled(t *testing.T) {
+	t.Parallel()
+	var contentEncoding string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		contentEncoding = req.Header.Get("Content-Encoding")
+		body, _ := ioutil.ReadAll(req.Body)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	require.NoError(t, fb.Set("foo"))
+	assert.Empty(t, contentEncoding)
+}
@@ -0,0 +1,163 @@
+package firego
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// WatchDebounced behaves like Watch, except that the put and patch events
+// received within the given interval are coalesced before being delivered:
+// only the latest data of every changed path is sent. Patch events are
+// merged into the pending event of their path rather than replacing it, and a
+// put event replaces the pending events of the locations below its path.
+// Any other event flushes the pending events and is delivered right away.
+func (fb *Firebase) WatchDebounced(notifications chan Event, interval time.Duration) error {
+	events := make(chan Event)
+	stop, done, err := fb.forwardWatch(events)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer close(done)
+		defer close(notifications)
+
+		var (
+			pending []Event
+			flush   <-chan time.Time
+		)
+		send := func(event Event) bool {
+			if isStopped(stop) {
+				return false
+			}
+			select {
+			case notifications <- event:
+				return true
+			case <-stop:
+				return false
+			}
+		}
+		sendPending := func() bool {
+			for _, event := range pending {
+				if !send(event) {
+					return false
+				}
+			}
+			pending, flush = nil, nil
+			return true
+		}
+
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					sendPending()
+					return
+				}
+
+				if event.Type != EventTypePut && event.Type != EventTypePatch {
+					if !sendPending() || !send(event) {
+						return
+					}
+					continue
+				}
+
+				pending = coalesce(pending, event)
+				if flush == nil {
+					flush = time.After(interval)
+				}
+			case <-flush:
+				if !sendPending() {
+					return
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// coalesce adds the put or patch event to the pending events, merging it
+// with the pending event of the same path when no later pending event
+// touches that path.
+func coalesce(pending []Event, event Event) []Event {
+	if event.Type == EventTypePut {
+		// the put replaces everything at and below its path
+		kept := pending[:0]
+		for _, p := range pending {
+			if !isSubPath(event.Path, p.Path) {
+				kept = append(kept, p)
+			}
+		}
+		return append(kept, event)
+	}
+
+	for i := len(pending) - 1; i >= 0; i-- {
+		p := pending[i]
+		if p.Path == event.Path {
+			if !isShallowPatch(event) || (p.Type == EventTypePatch && !isShallowPatch(p)) {
+				break
+			}
+			pending[i] = mergePatch(p, event)
+			return pending
+		}
+		if isSubPath(p.Path, event.Path) || isSubPath(event.Path, p.Path) {
+			// merging further back would reorder overlapping changes
+			break
+		}
+	}
+	return append(pending, event)
+}
+
+// mergePatch applies the patch event to the given pending event of the same
+// path, the result is a put if the pending event was a put.
+func mergePatch(pending, patch Event) Event {
+	changes, _ := patch.Data.(map[string]interface{})
+	merged := map[string]interface{}{}
+	if data, ok := pending.Data.(map[string]interface{}); ok {
+		for k, v := range data {
+			merged[k] = v
+		}
+	}
+	for k, v := range changes {
+		if v == nil && pending.Type == EventTypePut {
+			// a null child of a patch removes the child
+			delete(merged, k)
+			continue
+		}
+		merged[k] = v
+	}
+
+	event := pending
+	event.Data = merged
+	if pending.Type == EventTypePut && len(merged) == 0 {
+		event.Data = nil
+	}
+	// keep Value working on the merged data
+	event.rawData, _ = json.Marshal(map[string]interface{}{
+		"path": event.Path,
+		"data": event.Data,
+	})
+	return event
+}
+
+// isShallowPatch reports whether every key changed by the patch
+// event is a direct child of its path.
+func isShallowPatch(patch Event) bool {
+	changes, ok := patch.Data.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for k := range changes {
+		if strings.Contains(k, "/") {
+			return false
+		}
+	}
+	return true
+}
+
+// isSubPath reports whether path is at or below parent.
+func isSubPath(parent, path string) bool {
+	parent, path = strings.Trim(parent, "/"), strings.Trim(path, "/")
+	return parent == "" || path == parent || strings.HasPrefix(path, parent+"/")
+}
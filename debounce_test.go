@@ -0,0 +1,97 @@
+package firego
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoalesce(t *testing.T) {
+	t.Parallel()
+	var pending []Event
+	for _, event := range []Event{
+		{Type: EventTypePut, Path: "/a", Data: map[string]interface{}{"x": 1.0, "y": 1.0}},
+		{Type: EventTypePatch, Path: "/a", Data: map[string]interface{}{"y": 2.0, "x": nil}},
+		{Type: EventTypePatch, Path: "/b", Data: map[string]interface{}{"z": 1.0}},
+		{Type: EventTypePatch, Path: "/b", Data: map[string]interface{}{"w": 1.0}},
+		{Type: EventTypePut, Path: "/c/d", Data: 1.0},
+		{Type: EventTypePut, Path: "/c", Data: 2.0},
+		{Type: EventTypePut, Path: "/e/f", Data: 3.0},
+		{Type: EventTypePut, Path: "/e", Data: map[string]interface{}{"f": 2.0}},
+		{Type: EventTypePut, Path: "/e/f", Data: 4.0},
+	} {
+		pending = coalesce(pending, event)
+	}
+
+	require.Len(t, pending, 5)
+	assert.Equal(t, EventTypePut, pending[0].Type)
+	assert.Equal(t, map[string]interface{}{"y": 2.0}, pending[0].Data)
+	var v map[string]int
+	require.NoError(t, pending[0].Value(&v))
+	assert.Equal(t, map[string]int{"y": 2}, v)
+
+	assert.Equal(t, EventTypePatch, pending[1].Type)
+	assert.Equal(t, map[string]interface{}{"z": 1.0, "w": 1.0}, pending[1].Data)
+
+	assert.Equal(t, "/c", pending[2].Path)
+	assert.Equal(t, 2.0, pending[2].Data)
+
+	// the later put of /e/f must not be merged before the put of /e
+	assert.Equal(t, "/e", pending[3].Path)
+	assert.Equal(t, "/e/f", pending[4].Path)
+	assert.Equal(t, 4.0, pending[4].Data)
+}
+
+func TestWatchDebounced(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: put\ndata: {\"path\":\"/\",\"data\":null}\n\n")
+		for i := 1; i <= 5; i++ {
+			fmt.Fprintf(w, "event: put\ndata: {\"path\":\"/foo\",\"data\":{\"n\":%d}}\n\n", i)
+		}
+		fmt.Fprint(w, "event: patch\ndata: {\"path\":\"/foo\",\"data\":{\"m\":1}}\n\n")
+		w.(http.Flusher).Flush()
+		<-req.Context().Done()
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	notifications := make(chan Event)
+	require.NoError(t, fb.WatchDebounced(notifications, 50*time.Millisecond))
+
+	var events []Event
+	timeout := time.After(250 * time.Millisecond)
+loop:
+	for {
+		select {
+		case event := <-notifications:
+			events = append(events, event)
+		case <-timeout:
+			break loop
+		}
+	}
+	require.Len(t, events, 2)
+	assert.Equal(t, "/", events[0].Path)
+	assert.Equal(t, "/foo", events[1].Path)
+	assert.Equal(t, EventTypePut, events[1].Type)
+	assert.Equal(t, map[string]interface{}{"n": 5.0, "m": 1.0}, events[1].Data)
+
+	fb.StopWatching()
+	_, ok := <-notifications
+	assert.False(t, ok, "notifications should be closed")
+}
+
+func TestWatchDebounced_StopWatching(t *testing.T) {
+	t.Parallel()
+	testStopWatchingForwarded(t, func(fb *Firebase) interface{} {
+		notifications := make(chan Event)
+		require.NoError(t, fb.WatchDebounced(notifications, time.Millisecond))
+		return notifications
+	})
+}
@@ -0,0 +1,98 @@
+package firego
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+)
+
+// DryRunRequest describes a write that was not sent to Firebase
+// because the reference is in dry-run mode, see SetDryRun.
+type DryRunRequest struct {
+	// Method is the HTTP method of the request.
+	Method string
+	// URL is the URL of the request, without any credentials.
+	URL string
+	// Body is the JSON data that would have been sent.
+	Body []byte
+}
+
+// SetDryRun enables or disables the dry-run mode of the reference. In
+// dry-run mode, writes (Set, Update, Push, Remove and their variants,
+// Upload and the DoRaw requests other than GET) are not sent to Firebase
+// but reported to the functions registered with OnDryRun, and succeed as
+// if Firebase had accepted them. Reads are sent
+// as usual, so they do not reflect the writes that were skipped.
+//
+// The mode is inherited by references derived from this one.
+func (fb *Firebase) SetDryRun(enabled bool) {
+	fb.paramsMtx.Lock()
+	fb.dryRun = enabled
+	fb.paramsMtx.Unlock()
+}
+
+// OnDryRun registers a function that is called with every write skipped
+// in dry-run mode, such as to log what a script would do.
+//
+// Hooks are inherited by references derived from this one.
+func (fb *Firebase) OnDryRun(hook func(req DryRunRequest)) {
+	fb.paramsMtx.Lock()
+	fb.dryRunHooks = append(fb.dryRunHooks[:len(fb.dryRunHooks):len(fb.dryRunHooks)], hook)
+	fb.paramsMtx.Unlock()
+}
+
+func (fb *Firebase) getDryRun() (bool, []func(DryRunRequest)) {
+	fb.paramsMtx.RLock()
+	defer fb.paramsMtx.RUnlock()
+	return fb.dryRun, fb.dryRunHooks
+}
+
+// skipRequest reports the request to the dry-run hooks instead of sending it
+// and returns the response Firebase would send back for a successful write.
+func (fb *Firebase) skipRequest(ctx context.Context, method string, body []byte, hooks []func(DryRunRequest), options ...func(*http.Request)) (http.Header, []byte, error) {
+	if fb.pathErr != nil {
+		return nil, nil, fb.pathErr
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, fb.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, opt := range options {
+		opt(req)
+	}
+
+	info := DryRunRequest{
+		Method: method,
+		URL:    redactURL(req.URL),
+		Body:   body,
+	}
+	for _, hook := range hooks {
+		hook(info)
+	}
+
+	switch method {
+	case "POST":
+		resp, err := json.Marshal(map[string]string{"name": GenerateKey()})
+		return http.Header{}, resp, err
+	case "DELETE":
+		return http.Header{}, []byte("null"), nil
+	}
+	return http.Header{}, body, nil
+}
+
+// dryRunResponse returns the response of a request skipped by DoRaw.
+func dryRunResponse(headers http.Header, body []byte) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        headers,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+}
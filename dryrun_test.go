@@ -0,0 +1,91 @@
+package firego
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trevor403/firego/firetest"
+)
+
+func TestDryRun(t *testing.T) {
+	t.Parallel()
+	server := firetest.New()
+	server.Start()
+	defer server.Close()
+	server.Set("/existing", "value")
+
+	var requests []DryRunRequest
+	fb := New(server.URL, nil)
+	fb.Auth("secret")
+	fb.SetDryRun(true)
+	fb.OnDryRun(func(req DryRunRequest) {
+		requests = append(requests, req)
+	})
+
+	require.NoError(t, fb.Child("foo").Set("bar"))
+	require.NoError(t, fb.Child("foo").Update(map[string]string{"a": "b"}))
+	pushed, err := fb.Child("list").Push(1)
+	require.NoError(t, err)
+	require.NoError(t, fb.Child("existing").Remove())
+
+	require.Len(t, requests, 4)
+	assert.Equal(t, "PUT", requests[0].Method)
+	assert.Equal(t, server.URL+"/foo/.json", requests[0].URL)
+	assert.Equal(t, `"bar"`, string(requests[0].Body))
+	assert.Equal(t, "PATCH", requests[1].Method)
+	assert.Equal(t, `{"a":"b"}`, string(requests[1].Body))
+	assert.Equal(t, "POST", requests[2].Method)
+	assert.Equal(t, "DELETE", requests[3].Method)
+	assert.Nil(t, requests[3].Body)
+	assert.NotEqual(t, server.URL+"/list", pushed.URL())
+
+	// nothing was written but reads still go through
+	assert.Nil(t, server.Get("/foo"))
+	var v string
+	require.NoError(t, fb.Child("existing").Value(&v))
+	assert.Equal(t, "value", v)
+
+	fb.SetDryRun(false)
+	require.NoError(t, fb.Child("foo").Set("bar"))
+	assert.Equal(t, "bar", server.Get("/foo"))
+	assert.Len(t, requests, 4)
+}
+
+func TestDryRun_Streams(t *testing.T) {
+	t.Parallel()
+	server := newTestServer(`"raw"`)
+	defer server.Close()
+
+	var requests []DryRunRequest
+	fb := New(server.URL, nil)
+	fb.SetDryRun(true)
+	fb.OnDryRun(func(req DryRunRequest) {
+		requests = append(requests, req)
+	})
+
+	require.NoError(t, fb.Upload(strings.NewReader(`{"big":"document"}`)))
+	resp, err := fb.DoRaw("PATCH", []byte(`{"a":1}`))
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `{"a":1}`, string(body))
+	assert.Len(t, server.receivedReqs, 0)
+
+	require.Len(t, requests, 2)
+	assert.Equal(t, "PUT", requests[0].Method)
+	assert.Equal(t, server.URL+"/.json?print=silent", requests[0].URL)
+	assert.Equal(t, `{"big":"document"}`, string(requests[0].Body))
+	assert.Equal(t, "PATCH", requests[1].Method)
+
+	// reads are sent
+	resp, err = fb.DoRaw("GET", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Len(t, server.receivedReqs, 1)
+}
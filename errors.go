@@ -0,0 +1,127 @@
+package firego
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrPreconditionFailed is returned when a conditional request is rejected
+// because the data at the location no longer matches the given ETag.
+var ErrPreconditionFailed = errors.New("precondition failed: etag does not match")
+
+// ErrNotModified is returned by a conditional read when the data
+// at the location still matches the given ETag.
+var ErrNotModified = errors.New("not modified: etag matches")
+
+// ErrTransactionAttemptsExceeded is returned by Transaction when the data kept
+// changing and the result could not be written within the configured attempts.
+var ErrTransactionAttemptsExceeded = errors.New("transaction attempts exceeded")
+
+// ErrPayloadTooLarge is returned, before any request is made, when the
+// encoded value of a write exceeds the limit set with SetMaxWriteSize.
+var ErrPayloadTooLarge = errors.New("payload too large")
+
+// ErrResponseTooLarge is returned when the response to a request
+// exceeds the limit set with SetMaxResponseBytes.
+var ErrResponseTooLarge = errors.New("response too large")
+
+// ErrThrottled matches, with errors.Is, the FirebaseError of a request
+// rejected because too many requests are being made, either with a 429 Too
+// Many Requests response or because the database is overloaded. The
+// RetryAfter field of the FirebaseError tells how long to wait, if known.
+var ErrThrottled = errors.New("throttled")
+
+// ErrAlreadyWatching is returned by Watch when the reference is already
+// watching, see StopWatching.
+var ErrAlreadyWatching = errors.New("already watching")
+
+// FirebaseError is returned when Firebase responds to a request
+// with a non-2xx status code.
+type FirebaseError struct {
+	// StatusCode is the HTTP status code returned by Firebase.
+	StatusCode int
+	// Message is the error message of the JSON body returned by Firebase,
+	// such as "Permission denied". It is empty if the body is not JSON.
+	Message string
+	// Body is the raw response body returned by Firebase.
+	Body string
+	// RetryAfter is how long Firebase asked to wait before making another
+	// request, read from the Retry-After header of the response. It is zero
+	// when the header is missing.
+	RetryAfter time.Duration
+}
+
+// newFirebaseError builds the error of a response with the given status
+// code, header and body, whose message is read from the {"error": "..."}
+// envelope Firebase usually sends.
+func newFirebaseError(statusCode int, header http.Header, body []byte) *FirebaseError {
+	var envelope struct {
+		Error string `json:"error"`
+	}
+	json.Unmarshal(body, &envelope)
+	return &FirebaseError{
+		StatusCode: statusCode,
+		Message:    envelope.Error,
+		Body:       string(body),
+		RetryAfter: parseRetryAfter(header.Get("Retry-After")),
+	}
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is
+// either a number of seconds or a date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && time.Until(date) > 0 {
+		return time.Until(date)
+	}
+	return 0
+}
+
+// Error returns the message of the error, or the raw body
+// of the response when it has no message.
+func (e *FirebaseError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return e.Body
+}
+
+// Is makes errors.Is report that the error matches ErrThrottled
+// when the request was throttled.
+func (e *FirebaseError) Is(target error) bool {
+	return target == ErrThrottled && e.throttled()
+}
+
+func (e *FirebaseError) throttled() bool {
+	return e.StatusCode == http.StatusTooManyRequests ||
+		(e.StatusCode >= 500 && strings.Contains(strings.ToLower(e.Message), "overloaded"))
+}
+
+// IsNotFound reports whether err is a FirebaseError caused by
+// a 404 Not Found response.
+func IsNotFound(err error) bool {
+	return hasStatusCode(err, http.StatusNotFound)
+}
+
+// IsUnauthorized reports whether err is a FirebaseError caused by
+// a 401 Unauthorized response.
+func IsUnauthorized(err error) bool {
+	return hasStatusCode(err, http.StatusUnauthorized)
+}
+
+func hasStatusCode(err error, code int) bool {
+	var fbErr *FirebaseError
+	if !errors.As(err, &fbErr) {
+		return false
+	}
+	return fbErr.StatusCode == code
+}
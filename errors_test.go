@@ -0,0 +1,148 @@
+package firego
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trevor403/firego/firetest"
+)
+
+func TestFirebaseError(t *testing.T) {
+	t.Parallel()
+	server := firetest.New()
+	server.Start()
+	defer server.Close()
+
+	server.RequireAuth(true)
+	fb := New(server.URL, nil)
+
+	err := fb.Value(new(interface{}))
+	require.Error(t, err)
+	require.IsType(t, (*FirebaseError)(nil), err)
+
+	fbErr := err.(*FirebaseError)
+	assert.Equal(t, http.StatusUnauthorized, fbErr.StatusCode)
+	assert.Equal(t, "Could not parse auth token.", fbErr.Message)
+	assert.Equal(t, fbErr.Message, err.Error())
+	assert.JSONEq(t, `{"error": "Could not parse auth token."}`, fbErr.Body)
+	assert.True(t, IsUnauthorized(err))
+	assert.False(t, IsNotFound(err))
+}
+
+func TestFirebaseError_Redirect(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// a redirect without Location cannot be followed
+		w.WriteHeader(http.StatusFound)
+		w.Write([]byte(`"moved"`))
+	}))
+	defer server.Close()
+
+	var v string
+	err := New(server.URL, nil).Value(&v)
+	require.Error(t, err)
+	assert.True(t, hasStatusCode(err, http.StatusFound), "%v", err)
+	assert.Empty(t, v)
+}
+
+func TestFirebaseErrorMessage(t *testing.T) {
+	t.Parallel()
+	for _, test := range []struct {
+		body     string
+		expected string
+	}{
+		{`{"error":"Permission denied"}`, "Permission denied"},
+		{`{"error":""}`, `{"error":""}`},
+		{`{"status":"failed"}`, `{"status":"failed"}`},
+		{`<html>Bad gateway</html>`, `<html>Bad gateway</html>`},
+		{``, ``},
+	} {
+		err := newFirebaseError(http.StatusBadRequest, nil, []byte(test.body))
+		assert.Equal(t, test.expected, err.Error(), test.body)
+		assert.Equal(t, test.body, err.Body)
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	for _, err := range []error{
+		fb.Set(true),
+		fb.Update(true),
+		fb.Remove(),
+		fb.Value(new(interface{})),
+	} {
+		require.Error(t, err)
+		assert.True(t, IsNotFound(err))
+		assert.True(t, IsNotFound(fmt.Errorf("wrapped: %w", err)))
+		assert.False(t, IsUnauthorized(err))
+	}
+
+	_, err := fb.Push(true)
+	assert.True(t, IsNotFound(err))
+	assert.False(t, IsNotFound(nil))
+}
+
+func TestErrThrottled(t *testing.T) {
+	t.Parallel()
+	for _, test := range []struct {
+		status    int
+		header    http.Header
+		body      string
+		throttled bool
+		expected  time.Duration
+	}{
+		{http.StatusTooManyRequests, http.Header{"Retry-After": {"3"}}, ``, true, 3 * time.Second},
+		{http.StatusTooManyRequests, nil, ``, true, 0},
+		{http.StatusServiceUnavailable, nil, `{"error":"Database overloaded"}`, true, 0},
+		{http.StatusServiceUnavailable, http.Header{"Retry-After": {"soon"}}, ``, false, 0},
+		{http.StatusBadRequest, nil, `{"error":"overloaded"}`, false, 0},
+	} {
+		err := newFirebaseError(test.status, test.header, []byte(test.body))
+		assert.Equal(t, test.throttled, errors.Is(err, ErrThrottled), "%d %s", test.status, test.body)
+		assert.Equal(t, test.expected, err.RetryAfter, "%d %s", test.status, test.body)
+	}
+
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	err := newFirebaseError(http.StatusTooManyRequests, http.Header{"Retry-After": {date}}, nil)
+	assert.InDelta(t, float64(time.Minute), float64(err.RetryAfter), float64(2*time.Second))
+}
+
+func TestErrThrottled_Retry(t *testing.T) {
+	t.Parallel()
+	var requests []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests = append(requests, time.Now())
+		if len(requests) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, "true")
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	fb.SetRetry(2, nil)
+	var v bool
+	require.NoError(t, fb.Value(&v))
+	assert.True(t, v)
+	require.Len(t, requests, 2)
+	assert.True(t, requests[1].Sub(requests[0]) >= time.Second)
+
+	fb.SetRetry(1, nil)
+	requests = nil
+	err := fb.Value(&v)
+	assert.True(t, errors.Is(err, ErrThrottled), "%v", err)
+}
@@ -0,0 +1,80 @@
+package firego
+
+import (
+	"context"
+	"net/http"
+)
+
+const (
+	etagHeader        = "ETag"
+	etagRequestHeader = "X-Firebase-ETag"
+	ifMatchHeader     = "if-match"
+	ifNoneMatchHeader = "if-none-match"
+)
+
+// GetWithETag gets the value of the Firebase reference along with
+// the ETag that identifies the current state of the data.
+// The ETag can later be given to SetIfMatch.
+//
+// Reference https://firebase.google.com/docs/database/rest/app-management#conditional-requests
+func (fb *Firebase) GetWithETag(v interface{}) (string, error) {
+	headers, bytes, err := fb.doRequest(context.Background(), "GET", nil, withHeader(etagRequestHeader, "true"))
+	if err != nil {
+		return "", err
+	}
+	if err := fb.unmarshal(bytes, v); err != nil {
+		return "", err
+	}
+	return headers.Get(etagHeader), nil
+}
+
+// GetIfNoneMatch gets the value of the Firebase reference only if the data
+// at the location no longer matches the given ETag and returns the new ETag.
+// ErrNotModified is returned, and v left untouched, if the data is unchanged.
+//
+// Reference https://firebase.google.com/docs/database/rest/app-management#conditional-requests
+func (fb *Firebase) GetIfNoneMatch(v interface{}, etag string) (string, error) {
+	headers, bytes, err := fb.doRequest(context.Background(), "GET", nil,
+		withHeader(etagRequestHeader, "true"),
+		withHeader(ifNoneMatchHeader, etag),
+	)
+	if err != nil {
+		return "", err
+	}
+	if err := fb.unmarshal(bytes, v); err != nil {
+		return "", err
+	}
+	return headers.Get(etagHeader), nil
+}
+
+// SetIfMatch sets the value of the Firebase reference only if the data
+// at the location still matches the given ETag. ErrPreconditionFailed is
+// returned if the data has changed in the meantime.
+//
+// Reference https://firebase.google.com/docs/database/rest/app-management#conditional-requests
+func (fb *Firebase) SetIfMatch(v interface{}, etag string) error {
+	bytes, err := fb.marshalValue(v)
+	if err != nil {
+		return err
+	}
+	_, _, err = fb.doRequest(context.Background(), "PUT", bytes, withHeader(ifMatchHeader, etag))
+	return preconditionError(err)
+}
+
+// RemoveIfMatch removes the Firebase reference only if the data at the
+// location still matches the given ETag. ErrPreconditionFailed is returned
+// if the data has changed in the meantime.
+//
+// Reference https://firebase.google.com/docs/database/rest/app-management#conditional-requests
+func (fb *Firebase) RemoveIfMatch(etag string) error {
+	_, _, err := fb.doRequest(context.Background(), "DELETE", nil, withHeader(ifMatchHeader, etag))
+	return preconditionError(err)
+}
+
+// preconditionError converts a 412 response into ErrPreconditionFailed.
+func preconditionError(err error) error {
+	if hasStatusCode(err, http.StatusPreconditionFailed) {
+		return ErrPreconditionFailed
+	}
+	return err
+}
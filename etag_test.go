@@ -0,0 +1,96 @@
+package firego
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newETagServer(etag, value string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodGet {
+			if req.Header.Get(etagRequestHeader) == "true" {
+				w.Header().Set(etagHeader, etag)
+			}
+			if req.Header.Get(ifNoneMatchHeader) == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Write([]byte(value))
+			return
+		}
+
+		if req.Header.Get(ifMatchHeader) != etag {
+			w.Header().Set(etagHeader, etag)
+			w.WriteHeader(http.StatusPreconditionFailed)
+			w.Write([]byte(value))
+			return
+		}
+
+		body, _ := ioutil.ReadAll(req.Body)
+		w.Write(body)
+	}))
+}
+
+func TestGetWithETag(t *testing.T) {
+	t.Parallel()
+	server := newETagServer("abc", `"foo"`)
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	var v string
+	etag, err := fb.GetWithETag(&v)
+	require.NoError(t, err)
+	assert.Equal(t, "abc", etag)
+	assert.Equal(t, "foo", v)
+}
+
+func TestSetIfMatch(t *testing.T) {
+	t.Parallel()
+	server := newETagServer("abc", `"foo"`)
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	assert.NoError(t, fb.SetIfMatch("bar", "abc"))
+	assert.Equal(t, ErrPreconditionFailed, fb.SetIfMatch("bar", "def"))
+}
+
+func TestGetIfNoneMatch(t *testing.T) {
+	t.Parallel()
+	server := newETagServer("abc", `"foo"`)
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	var v string
+	etag, err := fb.GetIfNoneMatch(&v, "old")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", etag)
+	assert.Equal(t, "foo", v)
+
+	v = ""
+	etag, err = fb.GetIfNoneMatch(&v, "abc")
+	assert.Equal(t, ErrNotModified, err)
+	assert.Empty(t, etag)
+	assert.Empty(t, v)
+}
+
+func TestRemoveIfMatch(t *testing.T) {
+	t.Parallel()
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		methods = append(methods, req.Method)
+		if req.Header.Get(ifMatchHeader) != "abc" {
+			w.WriteHeader(http.StatusPreconditionFailed)
+		}
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	assert.NoError(t, fb.RemoveIfMatch("abc"))
+	assert.Equal(t, ErrPreconditionFailed, fb.RemoveIfMatch("def"))
+	assert.Equal(t, []string{"DELETE", "DELETE"}, methods)
+}
@@ -1,6 +1,7 @@
 package firego
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sort"
@@ -226,7 +227,7 @@ func (fb *Firebase) addEventFunc(fn ChildEventFunc, handleSSE handleSSEFunc) err
 	}
 
 	fb.eventFuncs[key] = stop
-	notifications, err := fb.watch(stop)
+	notifications, err := fb.watch(context.Background(), stop)
 	if err != nil {
 		return err
 	}
@@ -253,7 +254,7 @@ func (fb *Firebase) addEventFunc(fn ChildEventFunc, handleSSE handleSSEFunc) err
 		time.Sleep(backoff)
 
 		// try and reconnect
-		for notifications, err = fb.watch(stop); err != nil; time.Sleep(backoff) {
+		for notifications, err = fb.watch(context.Background(), stop); err != nil; time.Sleep(backoff) {
 			fb.eventMtx.Lock()
 			if _, ok := fb.eventFuncs[key]; !ok {
 				fb.eventMtx.Unlock()
@@ -71,6 +71,9 @@ func TestChildAddedReconnect(t *testing.T) {
 
 	fb = New(server.URL, nil)
 	fb.watchHeartbeat = 50 * time.Millisecond
+	// the reconnections must not read TimeoutDuration,
+	// which TestTimeoutDuration_Live changes
+	fb.SetTimeout(TimeoutDuration)
 
 	addNotifications := make(chan Event)
 	// use this to sync up between different events
@@ -84,6 +87,7 @@ func TestChildAddedReconnect(t *testing.T) {
 	}
 	err := fb.ChildAdded(fn)
 	require.NoError(t, err)
+	defer fb.RemoveEventFunc(fn)
 
 	readNotification(t, addNotifications)
 	readNotification(t, addNotifications)
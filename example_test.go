@@ -27,6 +27,16 @@ func ExampleFirebase_Shallow() {
 	fb.Shallow(false)
 }
 
+func ExampleFirebase_ShallowRef() {
+	fb := firego.New("https://someapp.firebaseio.com", nil)
+	var keys map[string]bool
+	if err := fb.Child("users").ShallowRef().Value(&keys); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Found %d users\n", len(keys))
+}
+
 func ExampleFirebase_IncludePriority() {
 	fb := firego.New("https://someapp.firebaseio.com", nil)
 	// Set value
@@ -5,21 +5,29 @@ package firego
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
 	_url "net/url"
+	"runtime/debug"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
+
+	"golang.org/x/time/rate"
 )
 
 // TimeoutDuration is the length of time any request will have to establish
 // a connection and receive headers from Firebase before returning
-// an ErrTimeout error.
+// an ErrTimeout error. Changing it applies to the following requests of every
+// reference that has no timeout of its own, see SetTimeout.
 var TimeoutDuration = 30 * time.Second
 
 var defaultRedirectLimit = 30
@@ -42,10 +50,59 @@ const (
 	startAtParam      = "startAt"
 	endAtParam        = "endAt"
 	equalToParam      = "equalTo"
+	printParam        = "print"
+	nsParam           = "ns"
+	authOverrideParam = "auth_variable_override"
+	printSilentVal    = "silent"
 )
 
 const defaultHeartbeat = 2 * time.Minute
 
+const defaultReconnectDelay = time.Second
+
+const defaultReconnectMaxDelay = 30 * time.Second
+
+const defaultTransactionAttempts = 25
+
+const (
+	userAgentHeader = "User-Agent"
+	modulePath      = "github.com/trevor403/firego"
+)
+
+// defaultUserAgent identifies the requests made by this package.
+var defaultUserAgent = "firego/" + moduleVersion()
+
+// moduleVersion returns the version of this module
+// the running binary was built with.
+func moduleVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path != modulePath {
+				continue
+			}
+			if dep.Replace != nil {
+				dep = dep.Replace
+			}
+			if dep.Version != "" {
+				return dep.Version
+			}
+		}
+	}
+	return "devel"
+}
+
+// defaultMaxWriteSize is the largest write Firebase accepts through the REST API.
+const defaultMaxWriteSize = 256 << 20
+
+// connection pool settings of the client created by New, every request of
+// a reference goes to the same host so idle connections are kept per host
+const (
+	defaultMaxIdleConns        = 100
+	defaultMaxIdleConnsPerHost = 100
+	defaultIdleConnTimeout     = 90 * time.Second
+	defaultTLSHandshakeTimeout = 10 * time.Second
+)
+
 type Auth struct {
 	mux   sync.RWMutex
 	token string
@@ -71,13 +128,33 @@ func (a *Auth) Get() string {
 	return a.token
 }
 
+// AuthMode determines how credentials are sent to Firebase.
+type AuthMode int
+
+const (
+	// AuthQuery sends credentials as query parameters of the request URL.
+	// This is the default.
+	AuthQuery AuthMode = iota
+	// AuthHeader sends credentials in an "Authorization: Bearer" header so
+	// they don't end up in logs, proxies or redirect chains. This is meant
+	// for OAuth2 access tokens, legacy secrets are only accepted by Firebase
+	// as query parameters.
+	AuthHeader
+)
+
 // Firebase represents a location in the cloud.
 type Firebase struct {
 	url           string
+	pathErr       error
 	client        *http.Client
 	clientTimeout time.Duration
+	// defaultClient is set when client was created by New,
+	// the timeout is only enforced for that client
+	defaultClient bool
 
-	sharedAuth *Auth
+	sharedAuth  *Auth
+	tokenSource TokenSource
+	authMode    AuthMode
 
 	paramsMtx sync.RWMutex
 	params    _url.Values
@@ -85,42 +162,176 @@ type Firebase struct {
 	eventMtx   sync.Mutex
 	eventFuncs map[string]chan struct{}
 
-	watchMtx       sync.Mutex
-	watching       bool
-	watchHeartbeat time.Duration
-	stopWatching   chan struct{}
+	watchMtx            sync.Mutex
+	watching            bool
+	watchHeartbeat      time.Duration
+	watchReconnect      bool
+	watchKeepAlive      bool
+	watchReconnectDelay time.Duration
+	stopWatching        chan struct{}
+	// watchDone is closed once the current watch is over
+	watchDone chan struct{}
+	// watchForwardDone is closed once the goroutine forwarding the
+	// events of the current watch, if any, is over
+	watchForwardDone chan struct{}
+
+	watchReconnectMax    time.Duration
+	watchReconnectJitter float64
+	watchReconnectHook   func(attempt int, delay time.Duration)
+
+	transactionAttempts int
+	transactionBackoff  func(attempt int) time.Duration
+
+	retryAttempts int
+	retryBackoff  func(attempt int) time.Duration
+
+	decoderOptions []func(*json.Decoder)
+	compression    bool
+	pageSize       int
+
+	requestHooks  []func(*http.Request)
+	responseHooks []func(RequestInfo)
+	timingHooks   []func(string, time.Duration)
+
+	multiConcurrency int
+
+	headers http.Header
+
+	redirectLimit int
+
+	dryRun      bool
+	dryRunHooks []func(DryRunRequest)
+
+	startAtKey string
+	endAtKey   string
+
+	marshalFunc   func(interface{}) ([]byte, error)
+	unmarshalFunc func([]byte, interface{}) error
+
+	maxWriteSize     int
+	maxResponseBytes int64
+
+	limiter *rate.Limiter
+
+	indexWarningHandler func(path, field string)
+
+	queue *writeQueue
+
+	// cache is not copied, see WithCache
+	cache *valueCache
 }
 
-// New creates a new Firebase reference,
-// if client is nil, http.DefaultClient is used.
+// New creates a new Firebase reference to the given URL, which can be the
+// host of a database of any region, such as mydb.firebaseio.com or
+// mydb.europe-west1.firebasedatabase.app, https being used when no scheme
+// is given. See NewDatabase to build the URL from the name of the database.
+// If client is nil, a client enforcing the timeout of the reference and
+// pooling connections to Firebase is used, see SetTransportOptions.
 func New(url string, client *http.Client) *Firebase {
 	fb := &Firebase{
-		url:            sanitizeURL(url),
 		params:         _url.Values{},
-		clientTimeout:  TimeoutDuration,
 		stopWatching:   make(chan struct{}),
 		watchHeartbeat: defaultHeartbeat,
 		eventFuncs:     map[string]chan struct{}{},
+
+		watchReconnectDelay: defaultReconnectDelay,
+		watchReconnectMax:   defaultReconnectMaxDelay,
+		transactionAttempts: defaultTransactionAttempts,
 	}
-	if client == nil {
-		var tr *http.Transport
-		tr = &http.Transport{
-			Dial: func(network, address string) (net.Conn, error) {
-				start := time.Now()
-				c, err := net.DialTimeout(network, address, fb.clientTimeout)
-				tr.ResponseHeaderTimeout = fb.clientTimeout - time.Since(start)
-				return c, err
-			},
-		}
+	fb.setURL(url)
+	fb.SetClient(client)
+	fb.SetUserAgent(defaultUserAgent)
+	return fb
+}
 
-		client = &http.Client{
-			Transport:     tr,
-			CheckRedirect: redirectPreserveHeaders,
+// defaultRegion is the region of the databases served from firebaseio.com.
+const defaultRegion = "us-central1"
+
+// NewDatabase creates a new Firebase reference to the root of the database
+// with the given name in the given region, such as "europe-west1", see New
+// for the client. Databases of the default region, us-central1, which is
+// used when region is empty, are served from <name>.firebaseio.com and the
+// others from <name>.<region>.firebasedatabase.app.
+func NewDatabase(name, region string, client *http.Client) (*Firebase, error) {
+	url, err := DatabaseURL(name, region)
+	if err != nil {
+		return nil, err
+	}
+	return New(url, client), nil
+}
+
+// DatabaseURL returns the URL of the database with the given name in the
+// given region, see NewDatabase.
+func DatabaseURL(name, region string) (string, error) {
+	if err := validateHostLabel("database name", name); err != nil {
+		return "", err
+	}
+	if region == "" || region == defaultRegion {
+		return "https://" + name + ".firebaseio.com", nil
+	}
+	if err := validateHostLabel("region", region); err != nil {
+		return "", err
+	}
+	return "https://" + name + "." + region + ".firebasedatabase.app", nil
+}
+
+// validateHostLabel makes sure s can be used as a part of a host name.
+func validateHostLabel(kind, s string) error {
+	if s == "" {
+		return fmt.Errorf("invalid %s %q: %s is empty", kind, s, kind)
+	}
+	if s[0] == '-' || s[len(s)-1] == '-' {
+		return fmt.Errorf("invalid %s %q: %s cannot start or end with '-'", kind, s, kind)
+	}
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return fmt.Errorf("invalid %s %q: %s cannot contain %q", kind, s, kind, r)
 		}
 	}
+	return nil
+}
 
+// SetClient replaces the *http.Client used by the reference, references
+// derived from this one afterwards share the new client. If client is nil,
+// a client is created like in New.
+func (fb *Firebase) SetClient(client *http.Client) {
+	defaultClient := client == nil
+	if defaultClient {
+		client = fb.newDefaultClient()
+	}
+
+	fb.paramsMtx.Lock()
 	fb.client = client
-	return fb
+	fb.defaultClient = defaultClient
+	fb.paramsMtx.Unlock()
+}
+
+func (fb *Firebase) getClient() (client *http.Client, defaultClient bool) {
+	fb.paramsMtx.RLock()
+	defer fb.paramsMtx.RUnlock()
+	return fb.client, fb.defaultClient
+}
+
+func (fb *Firebase) newDefaultClient() *http.Client {
+	tr := &http.Transport{
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			// the rest of the timeout is enforced by roundTrip
+			dialer := net.Dialer{Timeout: fb.requestTimeout(ctx)}
+			return dialer.DialContext(ctx, network, address)
+		},
+		Proxy:               http.ProxyFromEnvironment,
+		MaxIdleConns:        defaultMaxIdleConns,
+		MaxIdleConnsPerHost: defaultMaxIdleConnsPerHost,
+		IdleConnTimeout:     defaultIdleConnTimeout,
+		TLSHandshakeTimeout: defaultTLSHandshakeTimeout,
+	}
+
+	return &http.Client{
+		Transport: tr,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return redirectPreserveHeaders(req, via, fb.requestRedirectLimit(req.Context()))
+		},
+	}
 }
 
 // Auth sets the custom Firebase token used to authenticate to Firebase.
@@ -137,6 +348,50 @@ func (fb *Firebase) Unauth() {
 	fb.paramsMtx.Unlock()
 }
 
+// WithAuth creates a new Firebase reference authenticating with the given
+// custom token instead of the credentials of the reference, which is left
+// untouched. The token source and the shared Auth of the reference are not
+// used by the new reference. This allows a reference shared by goroutines
+// to make requests on behalf of different users:
+//
+//	err := fb.Child("orders").WithAuth(userToken).Value(&orders)
+func (fb *Firebase) WithAuth(token string) *Firebase {
+	c := fb.copy()
+	c.params.Set(authParam, token)
+	c.sharedAuth = nil
+	c.tokenSource = nil
+	return c
+}
+
+// SetAuthOverride makes Firebase evaluate the security rules of the requests
+// made with admin credentials, such as an OAuth2 access token of a service
+// account, as if they were made by a user with the given claims, which
+// become the auth variable of the rules. A nil claims value evaluates the
+// rules as for an unauthenticated user. An error is returned if the claims
+// cannot be encoded as JSON.
+//
+//	fb.SetAuthOverride(map[string]interface{}{"uid": "alice"})
+//
+// Reference https://firebase.google.com/docs/database/rest/auth#authenticate_with_limited_privileges
+func (fb *Firebase) SetAuthOverride(claims interface{}) error {
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return fmt.Errorf("invalid auth override: %s", err)
+	}
+
+	fb.paramsMtx.Lock()
+	fb.params.Set(authOverrideParam, string(data))
+	fb.paramsMtx.Unlock()
+	return nil
+}
+
+// ClearAuthOverride removes the claims set with SetAuthOverride.
+func (fb *Firebase) ClearAuthOverride() {
+	fb.paramsMtx.Lock()
+	fb.params.Del(authOverrideParam)
+	fb.paramsMtx.Unlock()
+}
+
 // SetSharedAuth adds a referance to a shared auth token
 func (fb *Firebase) SetSharedAuth(auth *Auth) {
 	fb.paramsMtx.Lock()
@@ -144,20 +399,351 @@ func (fb *Firebase) SetSharedAuth(auth *Auth) {
 	fb.paramsMtx.Unlock()
 }
 
+// SetTimeout sets the length of time requests made through this reference
+// have to establish a connection and receive the response headers. A zero
+// duration, the default, uses the current value of TimeoutDuration. The
+// timeout is only enforced by the client created by New when a nil
+// *http.Client is given, custom clients are expected to configure their
+// own timeouts.
+func (fb *Firebase) SetTimeout(d time.Duration) {
+	fb.paramsMtx.Lock()
+	fb.clientTimeout = d
+	fb.paramsMtx.Unlock()
+}
+
+func (fb *Firebase) getTimeout() time.Duration {
+	fb.paramsMtx.RLock()
+	timeout := fb.clientTimeout
+	fb.paramsMtx.RUnlock()
+	if timeout == 0 {
+		return TimeoutDuration
+	}
+	return timeout
+}
+
+// timeoutKey is the request context key holding the timeout
+// of the reference that made the request.
+type timeoutKey struct{}
+
+// requestTimeout returns the timeout of the reference that made the request
+// with the given context. References derived from this one share its
+// transport, so the timeout cannot be read from fb when dialing.
+func (fb *Firebase) requestTimeout(ctx context.Context) time.Duration {
+	if timeout, ok := ctx.Value(timeoutKey{}).(time.Duration); ok {
+		return timeout
+	}
+	return fb.getTimeout()
+}
+
+// SetRedirectLimit sets how many consecutive redirects requests made through
+// this reference follow before failing. A zero limit, the default, allows 30
+// redirects. Like the timeout, the limit is only enforced by the client
+// created by New when a nil *http.Client is given.
+func (fb *Firebase) SetRedirectLimit(n int) {
+	fb.paramsMtx.Lock()
+	fb.redirectLimit = n
+	fb.paramsMtx.Unlock()
+}
+
+func (fb *Firebase) getRedirectLimit() int {
+	fb.paramsMtx.RLock()
+	limit := fb.redirectLimit
+	fb.paramsMtx.RUnlock()
+	if limit == 0 {
+		return defaultRedirectLimit
+	}
+	return limit
+}
+
+// redirectLimitKey is the request context key holding the redirect
+// limit of the reference that made the request.
+type redirectLimitKey struct{}
+
+// requestRedirectLimit returns the redirect limit of the reference that made
+// the request with the given context, see requestTimeout.
+func (fb *Firebase) requestRedirectLimit(ctx context.Context) int {
+	if limit, ok := ctx.Value(redirectLimitKey{}).(int); ok {
+		return limit
+	}
+	return fb.getRedirectLimit()
+}
+
+// SetTransportOptions calls fn with the transport of the reference client
+// so that its settings, such as the connection pool size, can be tuned.
+// The transport is shared with every reference derived from this one and
+// should only be changed before any request is made.
+// An error is returned if the client transport is not an *http.Transport.
+func (fb *Firebase) SetTransportOptions(fn func(tr *http.Transport)) error {
+	client, _ := fb.getClient()
+	tr, ok := client.Transport.(*http.Transport)
+	if !ok {
+		return fmt.Errorf("cannot configure transport of type %T", client.Transport)
+	}
+	fn(tr)
+	return nil
+}
+
+// SetTLSConfig sets the TLS configuration of the client created by New,
+// such as to trust the custom root CAs of a corporate proxy. Like with
+// SetTransportOptions, the configuration is shared with every reference
+// derived from this one. Custom clients given to New or SetClient are
+// not affected: an error is returned, their own transport should be
+// configured instead.
+func (fb *Firebase) SetTLSConfig(config *tls.Config) error {
+	if _, defaultClient := fb.getClient(); !defaultClient {
+		return errors.New("cannot set the TLS configuration of a custom client")
+	}
+	return fb.SetTransportOptions(func(tr *http.Transport) {
+		tr.TLSClientConfig = config
+	})
+}
+
+// SetMaxWriteSize sets the size, in bytes, above which the encoded value of
+// a write is rejected with an ErrPayloadTooLarge error instead of being sent.
+// A zero size, the default, uses the 256MB limit of Firebase.
+//
+// Reference https://firebase.google.com/docs/database/usage/limits
+func (fb *Firebase) SetMaxWriteSize(n int) {
+	fb.paramsMtx.Lock()
+	fb.maxWriteSize = n
+	fb.paramsMtx.Unlock()
+}
+
+func (fb *Firebase) checkWriteSize(body []byte) error {
+	fb.paramsMtx.RLock()
+	limit := fb.maxWriteSize
+	fb.paramsMtx.RUnlock()
+	if limit <= 0 {
+		limit = defaultMaxWriteSize
+	}
+	if len(body) > limit {
+		return fmt.Errorf("%w: %d bytes exceeds the limit of %d bytes", ErrPayloadTooLarge, len(body), limit)
+	}
+	return nil
+}
+
+// SetMaxResponseBytes limits how many bytes of a response, once
+// decompressed, are read into memory, so that reading an unexpectedly
+// large node fails with an ErrResponseTooLarge error instead. A zero
+// limit, the default, reads responses whatever their size. Download
+// is not limited since it does not hold the response in memory.
+func (fb *Firebase) SetMaxResponseBytes(n int64) {
+	fb.paramsMtx.Lock()
+	fb.maxResponseBytes = n
+	fb.paramsMtx.Unlock()
+}
+
+// readBody reads the given response body up to the limit set
+// with SetMaxResponseBytes.
+func (fb *Firebase) readBody(body io.Reader) ([]byte, error) {
+	fb.paramsMtx.RLock()
+	limit := fb.maxResponseBytes
+	fb.paramsMtx.RUnlock()
+	if limit <= 0 {
+		return ioutil.ReadAll(body)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrResponseTooLarge, limit)
+	}
+	return data, nil
+}
+
+// SetRetry configures how many times an idempotent request (GET, PUT and
+// DELETE) is attempted when it fails with a transient error, and how long to
+// wait before each new attempt. Only network errors, timeouts, 5xx responses
+// and throttled requests, see ErrThrottled, are retried, never other 4xx
+// responses. A nil backoff retries immediately, but a throttled request is
+// never retried before the delay sent by Firebase in Retry-After.
+func (fb *Firebase) SetRetry(maxAttempts int, backoff func(attempt int) time.Duration) {
+	fb.paramsMtx.Lock()
+	fb.retryAttempts = maxAttempts
+	fb.retryBackoff = backoff
+	fb.paramsMtx.Unlock()
+}
+
+func (fb *Firebase) getRetry() (maxAttempts int, backoff func(attempt int) time.Duration) {
+	fb.paramsMtx.RLock()
+	defer fb.paramsMtx.RUnlock()
+	return fb.retryAttempts, fb.retryBackoff
+}
+
+// SetDecoderOption configures the json.Decoder used to decode values read
+// from Firebase, including the data of streamed events. This is typically
+// used to keep the precision of large numbers:
+//
+//	fb.SetDecoderOption((*json.Decoder).UseNumber)
+func (fb *Firebase) SetDecoderOption(opts ...func(*json.Decoder)) {
+	fb.paramsMtx.Lock()
+	fb.decoderOptions = opts
+	fb.paramsMtx.Unlock()
+}
+
+// SetMarshalFunc replaces the function used to encode the values written
+// to Firebase, json.Marshal by default. The keys of the encoded values are
+// still validated. This allows using another JSON library:
+//
+//	fb.SetMarshalFunc(jsoniter.Marshal)
+func (fb *Firebase) SetMarshalFunc(fn func(v interface{}) ([]byte, error)) {
+	fb.paramsMtx.Lock()
+	fb.marshalFunc = fn
+	fb.paramsMtx.Unlock()
+}
+
+// SetUnmarshalFunc replaces the function used to decode the values read
+// from Firebase, including the data of streamed events. The options given
+// to SetDecoderOption are ignored once it is set.
+//
+//	fb.SetUnmarshalFunc(jsoniter.Unmarshal)
+func (fb *Firebase) SetUnmarshalFunc(fn func(data []byte, v interface{}) error) {
+	fb.paramsMtx.Lock()
+	fb.unmarshalFunc = fn
+	fb.paramsMtx.Unlock()
+}
+
+func (fb *Firebase) marshal(v interface{}) ([]byte, error) {
+	fb.paramsMtx.RLock()
+	marshal := fb.marshalFunc
+	fb.paramsMtx.RUnlock()
+	if marshal != nil {
+		return marshal(v)
+	}
+	return json.Marshal(v)
+}
+
+func (fb *Firebase) unmarshal(data []byte, v interface{}) error {
+	fb.paramsMtx.RLock()
+	unmarshal, opts := fb.unmarshalFunc, fb.decoderOptions
+	fb.paramsMtx.RUnlock()
+	if unmarshal != nil {
+		return unmarshal(data, v)
+	}
+	if len(opts) == 0 {
+		return json.Unmarshal(data, v)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for _, opt := range opts {
+		opt(dec)
+	}
+	return dec.Decode(v)
+}
+
+// SetAuthMode determines how credentials are sent to Firebase.
+func (fb *Firebase) SetAuthMode(mode AuthMode) {
+	fb.paramsMtx.Lock()
+	fb.authMode = mode
+	fb.paramsMtx.Unlock()
+}
+
+// SetHeader sets a header sent with every request of the reference, such as
+// a tracing ID or the credentials of a gateway in front of Firebase. It
+// replaces any value previously set for the same key. Headers are inherited
+// by references derived from this one. Like the credentials, they are only
+// preserved on redirects to the same host or between Firebase hosts, the
+// User-Agent excepted.
+func (fb *Firebase) SetHeader(key, value string) {
+	fb.paramsMtx.Lock()
+	if fb.headers == nil {
+		fb.headers = http.Header{}
+	}
+	fb.headers.Set(key, value)
+	fb.paramsMtx.Unlock()
+}
+
+// SetUserAgent sets the User-Agent header sent with every request, which
+// defaults to firego/<version>. An empty user agent sends the default
+// User-Agent of net/http instead.
+func (fb *Firebase) SetUserAgent(userAgent string) {
+	if userAgent == "" {
+		fb.DelHeader(userAgentHeader)
+		return
+	}
+	fb.SetHeader(userAgentHeader, userAgent)
+}
+
+// DelHeader removes a header set with SetHeader.
+func (fb *Firebase) DelHeader(key string) {
+	fb.paramsMtx.Lock()
+	fb.headers.Del(key)
+	fb.paramsMtx.Unlock()
+}
+
 // Ref returns a copy of an existing Firebase reference with a new path.
+// Like with Child, every key of the path is escaped.
 func (fb *Firebase) Ref(path string) (*Firebase, error) {
 	newFB := fb.copy()
 	parsedURL, err := _url.Parse(fb.url)
 	if err != nil {
 		return newFB, err
 	}
-	newFB.url = parsedURL.Scheme + "://" + parsedURL.Host + "/" + strings.Trim(path, "/")
+	path = cleanPath(path)
+	if err := validatePath(path); err != nil {
+		return newFB, err
+	}
+	newFB.url = parsedURL.Scheme + "://" + parsedURL.Host + "/" + escapePath(path)
+	newFB.pathErr = nil
 	return newFB, nil
 }
 
+// specialRef returns a reference to a special location of the database,
+// such as its rules, whose path is not made of valid keys and cannot be
+// given to Ref. The query of the reference is not kept.
+func (fb *Firebase) specialRef(path string) *Firebase {
+	ref := fb.Root()
+	ref.url += "/" + path
+	// explicitly not locking here because no one else
+	// has access to this reference.
+	for _, param := range queryParams {
+		ref.params.Del(param)
+	}
+	ref.startAtKey, ref.endAtKey = "", ""
+	// the writes of special locations, such as the rules, are not
+	// replayed later on behalf of the caller
+	ref.queue = nil
+	return ref
+}
+
 // SetURL changes the url for a firebase reference.
 func (fb *Firebase) SetURL(url string) {
+	fb.setURL(url)
+}
+
+// setURL sets the url of the reference. The query parameters of the url,
+// such as the namespace of emulator URLs like http://localhost:9000/?ns=project,
+// are merged into the parameters of the reference so that they are sent with
+// the requests of every derived reference.
+func (fb *Firebase) setURL(url string) {
+	var query string
+	if i := strings.Index(url, "?"); i >= 0 {
+		url, query = url[:i], url[i+1:]
+	}
 	fb.url = sanitizeURL(url)
+
+	values, _ := _url.ParseQuery(query)
+	fb.paramsMtx.Lock()
+	for k, v := range values {
+		fb.params[k] = v
+	}
+	fb.paramsMtx.Unlock()
+}
+
+// SetInsecure makes the reference talk plain http to its host when insecure
+// is true, instead of the https that New assumes for URLs given without a
+// scheme, which lets emulators and proxies on other hosts than localhost be
+// used with a bare host name. Passing false switches back to https. It only
+// affects the reference and the references derived from it afterwards.
+func (fb *Firebase) SetInsecure(insecure bool) {
+	scheme := "https://"
+	if insecure {
+		scheme = "http://"
+	}
+	host := strings.TrimPrefix(strings.TrimPrefix(fb.url, "https://"), "http://")
+	fb.url = scheme + host
 }
 
 // URL returns firebase reference URL
@@ -167,26 +753,75 @@ func (fb *Firebase) URL() string {
 
 // Push creates a reference to an auto-generated child location.
 func (fb *Firebase) Push(v interface{}) (*Firebase, error) {
-	bytes, err := json.Marshal(v)
+	return fb.PushWithContext(context.Background(), v)
+}
+
+// PushWithContext creates a reference to an auto-generated child location.
+// The request is aborted if the given context is canceled or expires.
+func (fb *Firebase) PushWithContext(ctx context.Context, v interface{}) (*Firebase, error) {
+	_, newRef, err := fb.pushKey(ctx, v)
+	return newRef, err
+}
+
+// PushKey creates a reference to an auto-generated child location
+// and returns the generated key along with it.
+func (fb *Firebase) PushKey(v interface{}) (string, *Firebase, error) {
+	return fb.pushKey(context.Background(), v)
+}
+
+// PushWithKey writes the value to a new child location like Push, except
+// that the key of the child is generated locally with GenerateKey and the
+// value is written with Set. The key and the reference to the child are
+// returned even if the write fails, so that it can be retried later at the
+// same location, such as when writes are queued while offline.
+func (fb *Firebase) PushWithKey(v interface{}) (string, *Firebase, error) {
+	key := GenerateKey()
+	newRef := fb.Child(key)
+	return key, newRef, newRef.Set(v)
+}
+
+// PushAndGet creates a reference to an auto-generated child location, like
+// Push, and then reads the value of the new child into out. This gets back
+// the values computed by Firebase, such as ServerTimestamp.
+func (fb *Firebase) PushAndGet(v, out interface{}) (*Firebase, error) {
+	newRef, err := fb.Push(v)
 	if err != nil {
 		return nil, err
 	}
-	_, bytes, err = fb.doRequest("POST", bytes)
+	return newRef, newRef.Value(out)
+}
+
+func (fb *Firebase) pushKey(ctx context.Context, v interface{}) (string, *Firebase, error) {
+	bytes, err := fb.marshalValue(v)
 	if err != nil {
-		return nil, err
+		return "", nil, err
+	}
+	return fb.pushRaw(ctx, bytes)
+}
+
+func (fb *Firebase) pushRaw(ctx context.Context, data []byte) (string, *Firebase, error) {
+	_, bytes, err := fb.doRequest(ctx, "POST", data)
+	if err != nil {
+		return "", nil, err
 	}
 	var m map[string]string
 	if err := json.Unmarshal(bytes, &m); err != nil {
-		return nil, err
+		return "", nil, err
 	}
 	newRef := fb.copy()
 	newRef.url = fb.url + "/" + m["name"]
-	return newRef, err
+	return m["name"], newRef, nil
 }
 
 // Remove the Firebase reference from the cloud.
 func (fb *Firebase) Remove() error {
-	_, _, err := fb.doRequest("DELETE", nil)
+	return fb.RemoveWithContext(context.Background())
+}
+
+// RemoveWithContext removes the Firebase reference from the cloud.
+// The request is aborted if the given context is canceled or expires.
+func (fb *Firebase) RemoveWithContext(ctx context.Context) error {
+	_, _, err := fb.doRequest(ctx, "DELETE", nil)
 	if err != nil {
 		return err
 	}
@@ -195,21 +830,115 @@ func (fb *Firebase) Remove() error {
 
 // Set the value of the Firebase reference.
 func (fb *Firebase) Set(v interface{}) error {
-	bytes, err := json.Marshal(v)
+	return fb.SetWithContext(context.Background(), v)
+}
+
+// SetWithContext sets the value of the Firebase reference.
+// The request is aborted if the given context is canceled or expires.
+func (fb *Firebase) SetWithContext(ctx context.Context, v interface{}) error {
+	bytes, err := fb.marshalValue(v)
 	if err != nil {
 		return err
 	}
-	_, _, err = fb.doRequest("PUT", bytes)
+	_, _, err = fb.doRequest(ctx, "PUT", bytes)
 	return err
 }
 
+// SetWithPriority sets the value of the Firebase reference along with the
+// priority used to order it among its siblings. The priority can be a
+// number or a string. See OrderByPriority.
+//
+// Reference https://firebase.google.com/docs/database/rest/save-data#section-priorities
+func (fb *Firebase) SetWithPriority(v interface{}, priority interface{}) error {
+	return fb.Set(map[string]interface{}{
+		".value":    v,
+		".priority": priority,
+	})
+}
+
 // Update the specific child with the given value.
 func (fb *Firebase) Update(v interface{}) error {
-	bytes, err := json.Marshal(v)
+	return fb.UpdateWithContext(context.Background(), v)
+}
+
+// UpdateWithContext updates the specific child with the given value.
+// The request is aborted if the given context is canceled or expires.
+func (fb *Firebase) UpdateWithContext(ctx context.Context, v interface{}) error {
+	bytes, err := fb.marshalUpdate(v)
+	if err != nil {
+		return err
+	}
+	_, _, err = fb.doRequest(ctx, "PATCH", bytes)
+	return err
+}
+
+// UpdateChildren atomically writes every value in the given map to the
+// location described by its key, relative to the current reference.
+// Keys may be deep paths such as "users/alice/name". An error is returned,
+// before any request is made, if a key is not a valid relative path.
+//
+// Reference https://firebase.google.com/docs/database/rest/save-data#section-multi-path-updates
+func (fb *Firebase) UpdateChildren(values map[string]interface{}) error {
+	for path := range values {
+		if err := validateRelativePath(path); err != nil {
+			return err
+		}
+	}
+	return fb.Update(values)
+}
+
+func validateRelativePath(path string) error {
+	switch {
+	case path == "":
+		return errors.New("invalid path: path is empty")
+	case strings.HasPrefix(path, "/"):
+		return fmt.Errorf("invalid path %q: path must be relative", path)
+	}
+
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" {
+			return fmt.Errorf("invalid path %q: path contains an empty segment", path)
+		}
+		if err := ValidateKey(segment); err != nil {
+			return fmt.Errorf("invalid path %q: %s", path, err)
+		}
+	}
+	return nil
+}
+
+// SetSilent sets the value of the Firebase reference without having
+// Firebase echo the written data back in the response.
+func (fb *Firebase) SetSilent(v interface{}) error {
+	bytes, err := fb.marshalValue(v)
+	if err != nil {
+		return err
+	}
+	_, _, err = fb.doRequest(context.Background(), "PUT", bytes, withParam(printParam, printSilentVal))
+	return err
+}
+
+// UpdateSilent updates the specific child with the given value without
+// having Firebase echo the written data back in the response.
+func (fb *Firebase) UpdateSilent(v interface{}) error {
+	bytes, err := fb.marshalUpdate(v)
+	if err != nil {
+		return err
+	}
+	_, _, err = fb.doRequest(context.Background(), "PATCH", bytes, withParam(printParam, printSilentVal))
+	return err
+}
+
+// PushSilent adds the value to an auto-generated child location without
+// having Firebase send back any response data.
+//
+// Since the name of the generated child is never returned, no reference to
+// it can be created. Use Push instead if the new location is needed.
+func (fb *Firebase) PushSilent(v interface{}) error {
+	bytes, err := fb.marshalValue(v)
 	if err != nil {
 		return err
 	}
-	_, _, err = fb.doRequest("PATCH", bytes)
+	_, _, err = fb.doRequest(context.Background(), "POST", bytes, withParam(printParam, printSilentVal))
 	return err
 }
 
@@ -218,13 +947,100 @@ func (fb *Firebase) Get(v interface{}) error {
 	return fb.Value(v)
 }
 
+// GetWithContext gets the value of the Firebase reference.
+// The request is aborted if the given context is canceled or expires.
+func (fb *Firebase) GetWithContext(ctx context.Context, v interface{}) error {
+	return fb.ValueWithContext(ctx, v)
+}
+
+// GetWithHeader gets the value of the Firebase reference and returns
+// the headers of the response, such as ETag or Content-Length.
+func (fb *Firebase) GetWithHeader(v interface{}) (http.Header, error) {
+	headers, bytes, err := fb.doRequest(context.Background(), "GET", nil)
+	if err != nil {
+		return headers, err
+	}
+	return headers, fb.unmarshal(bytes, v)
+}
+
+// ExportValue gets the value of the Firebase reference in the export format,
+// which includes the priority of every node under ".priority" keys and wraps
+// prioritized primitive values under ".value" keys. The exported data can be
+// written back unchanged with Set to restore both values and priorities.
+//
+// Reference https://firebase.google.com/docs/reference/rest/database#section-param-format
+func (fb *Firebase) ExportValue(v interface{}) error {
+	_, bytes, err := fb.doRequest(context.Background(), "GET", nil, withParam(formatParam, formatVal))
+	if err != nil {
+		return err
+	}
+	return fb.unmarshal(bytes, v)
+}
+
 // Value gets the value of the Firebase reference.
 func (fb *Firebase) Value(v interface{}) error {
-	_, bytes, err := fb.doRequest("GET", nil)
+	return fb.ValueWithContext(context.Background(), v)
+}
+
+// ValueWithContext gets the value of the Firebase reference.
+// The request is aborted if the given context is canceled or expires.
+func (fb *Firebase) ValueWithContext(ctx context.Context, v interface{}) error {
+	_, bytes, err := fb.doRequest(ctx, "GET", nil)
 	if err != nil {
 		return err
 	}
-	return json.Unmarshal(bytes, v)
+	return fb.unmarshal(bytes, v)
+}
+
+// ValueInto gets the value of the Firebase reference and reports whether
+// there was any data at the location. When Firebase returns null, v is left
+// untouched and found is false, so a missing node can be told apart from a
+// node holding the zero value of v.
+func (fb *Firebase) ValueInto(v interface{}) (found bool, err error) {
+	_, bytes, err := fb.doRequest(context.Background(), "GET", nil)
+	if err != nil {
+		return false, err
+	}
+	if isNull(bytes) {
+		return false, nil
+	}
+	return true, fb.unmarshal(bytes, v)
+}
+
+// Exists reports whether there is any data at the Firebase reference. The
+// read is shallow, see Shallow, so that no more than the keys of the
+// children are downloaded, unless the reference has a query Firebase does
+// not allow along with shallow.
+func (fb *Firebase) Exists() (bool, error) {
+	ref := fb.ShallowRef()
+	if validateQuery(ref.params) != nil {
+		ref = fb
+	}
+	var v json.RawMessage
+	return ref.ValueInto(&v)
+}
+
+// Ping checks that Firebase can be reached and that the credentials of the
+// reference allow reading its location, with a shallow read so that
+// no more than the keys of its children are downloaded. The query
+// configuration of the reference is ignored. The returned error is the
+// one of the read, such as a *FirebaseError or an ErrTimeout.
+func (fb *Firebase) Ping(ctx context.Context) error {
+	c := fb.copy()
+	// explicitly not locking here because no one else
+	// has access to this reference.
+	for _, param := range queryParams {
+		c.params.Del(param)
+	}
+	c.startAtKey, c.endAtKey = "", ""
+	c.params.Set(shallowParam, "true")
+
+	_, _, err := c.doRequest(ctx, "GET", nil)
+	return err
+}
+
+func isNull(data []byte) bool {
+	return string(bytes.TrimSpace(data)) == "null"
 }
 
 // String returns the string representation of the
@@ -253,37 +1069,188 @@ func (fb *Firebase) String() string {
 
 // Child creates a new Firebase reference for the requested
 // child with the same configuration as the parent.
+// The child may be a path of several keys separated by slashes,
+// each key is escaped so it can hold any character but '/'.
+// Like in Ref, leading, trailing and repeated slashes are ignored.
 func (fb *Firebase) Child(child string) *Firebase {
 	c := fb.copy()
-	c.url = c.url + "/" + child
+	child = cleanPath(child)
+	if child == "" {
+		return c
+	}
+	c.url = c.url + "/" + escapePath(child)
+	if c.pathErr == nil {
+		c.pathErr = validatePath(child)
+	}
 	return c
 }
 
+// Parent creates a new Firebase reference for the parent of the
+// location with the same configuration. It returns nil when the
+// reference is the root of the database, which has no parent.
+func (fb *Firebase) Parent() *Firebase {
+	root, path := splitURL(fb.url)
+	if path == "" {
+		return nil
+	}
+
+	c := fb.copy()
+	c.url = root
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		c.url += "/" + path[:i]
+	}
+	c.pathErr = validateEscapedPath(strings.TrimPrefix(c.url, root))
+	return c
+}
+
+// Root creates a new Firebase reference for the root of the database
+// with the same configuration.
+func (fb *Firebase) Root() *Firebase {
+	c := fb.copy()
+	c.url, _ = splitURL(fb.url)
+	c.pathErr = nil
+	return c
+}
+
+// Key returns the key of the location, the last segment of its path,
+// or an empty string when the reference is the root of the database.
+func (fb *Firebase) Key() string {
+	_, path := splitURL(fb.url)
+	key := path[strings.LastIndex(path, "/")+1:]
+	if unescaped, err := _url.PathUnescape(key); err == nil {
+		return unescaped
+	}
+	return key
+}
+
+// splitURL splits the url of a reference into the url of the database
+// root and the escaped path of the location, without surrounding slashes.
+func splitURL(url string) (root, path string) {
+	i := strings.Index(url, "://") + len("://")
+	j := strings.Index(url[i:], "/")
+	if j < 0 {
+		return url, ""
+	}
+	return url[:i+j], strings.Trim(url[i+j:], "/")
+}
+
+// validateEscapedPath validates a path made of escaped keys.
+func validateEscapedPath(path string) error {
+	path, err := _url.PathUnescape(strings.Trim(path, "/"))
+	if err != nil {
+		return err
+	}
+	return validatePath(path)
+}
+
+// cleanPath removes the leading, trailing and repeated slashes of a path.
+func cleanPath(path string) string {
+	segments := strings.FieldsFunc(path, func(r rune) bool { return r == '/' })
+	return strings.Join(segments, "/")
+}
+
+// escapePath escapes every segment of the given path.
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = _url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// Clone creates a new Firebase reference to the same location with the
+// same configuration, which can then be changed, with Auth or the query
+// methods for instance, without affecting the original reference. Watching
+// the clone is independent of watching the original. The client and the
+// Auth given to SetSharedAuth remain shared.
+func (fb *Firebase) Clone() *Firebase {
+	return fb.copy()
+}
+
 func (fb *Firebase) copy() *Firebase {
+	// every configuration field must be copied here so that
+	// derived references behave like their parent
+	fb.paramsMtx.RLock()
+	defer fb.paramsMtx.RUnlock()
+
 	c := &Firebase{
 		url:            fb.url,
+		pathErr:        fb.pathErr,
 		params:         _url.Values{},
 		client:         fb.client,
 		clientTimeout:  fb.clientTimeout,
+		defaultClient:  fb.defaultClient,
 		sharedAuth:     fb.sharedAuth,
+		tokenSource:    fb.tokenSource,
+		authMode:       fb.authMode,
 		stopWatching:   make(chan struct{}),
-		watchHeartbeat: defaultHeartbeat,
+		watchHeartbeat: fb.watchHeartbeat,
 		eventFuncs:     map[string]chan struct{}{},
+
+		transactionAttempts: fb.transactionAttempts,
+		transactionBackoff:  fb.transactionBackoff,
+
+		retryAttempts: fb.retryAttempts,
+		retryBackoff:  fb.retryBackoff,
+
+		decoderOptions: fb.decoderOptions,
+		compression:    fb.compression,
+		pageSize:       fb.pageSize,
+
+		requestHooks:  fb.requestHooks,
+		responseHooks: fb.responseHooks,
+		timingHooks:   fb.timingHooks,
+
+		multiConcurrency: fb.multiConcurrency,
+
+		headers:       fb.headers.Clone(),
+		redirectLimit: fb.redirectLimit,
+
+		dryRun:      fb.dryRun,
+		dryRunHooks: fb.dryRunHooks,
+
+		startAtKey: fb.startAtKey,
+		endAtKey:   fb.endAtKey,
+
+		marshalFunc:   fb.marshalFunc,
+		unmarshalFunc: fb.unmarshalFunc,
+
+		maxWriteSize:     fb.maxWriteSize,
+		maxResponseBytes: fb.maxResponseBytes,
+
+		limiter: fb.limiter,
+
+		indexWarningHandler: fb.indexWarningHandler,
+
+		queue: fb.queue,
 	}
 
+	// the watch settings are guarded by watchMtx
+	fb.watchMtx.Lock()
+	c.watchReconnect = fb.watchReconnect
+	c.watchKeepAlive = fb.watchKeepAlive
+	c.watchReconnectDelay = fb.watchReconnectDelay
+	c.watchReconnectMax = fb.watchReconnectMax
+	c.watchReconnectJitter = fb.watchReconnectJitter
+	c.watchReconnectHook = fb.watchReconnectHook
+	fb.watchMtx.Unlock()
+
 	// making sure to manually copy the map items into a new
 	// map to avoid modifying the map reference.
-	fb.paramsMtx.RLock()
 	for k, v := range fb.params {
-		c.params[k] = v
+		c.params[k] = append([]string(nil), v...)
 	}
-	fb.paramsMtx.RUnlock()
 	return c
 }
 
 func sanitizeURL(url string) string {
 	if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
-		url = "https://" + url
+		if isLocalhost(url) {
+			// the emulator does not serve https
+			url = "http://" + url
+		} else {
+			url = "https://" + url
+		}
 	}
 
 	if strings.HasSuffix(url, "/") {
@@ -293,76 +1260,498 @@ func sanitizeURL(url string) string {
 	return url
 }
 
+func isLocalhost(url string) bool {
+	for _, host := range []string{"localhost", "127.0.0.1", "[::1]"} {
+		if url == host || strings.HasPrefix(url, host+":") || strings.HasPrefix(url, host+"/") {
+			return true
+		}
+	}
+	return false
+}
+
 // Preserve headers on redirect.
 //
 // Reference https://github.com/golang/go/issues/4800
-func redirectPreserveHeaders(req *http.Request, via []*http.Request) error {
+func redirectPreserveHeaders(req *http.Request, via []*http.Request, limit int) error {
 	if len(via) == 0 {
 		// No redirects
 		return nil
 	}
 
-	if len(via) > defaultRedirectLimit {
+	if len(via) > limit {
 		return fmt.Errorf("%d consecutive requests(redirects)", len(via))
 	}
 
-	// mutate the subsequent redirect requests with the first Header
+	// mutate the subsequent redirect requests with the first Header,
+	// credentials are only sent to hosts trusted with the original request
+	trusted := trustedRedirect(via[0].URL, req.URL)
+	custom, _ := req.Context().Value(headersKey{}).(http.Header)
 	for key, val := range via[0].Header {
+		if !trusted && isSensitiveHeader(key, custom) {
+			continue
+		}
 		req.Header[key] = val
 	}
+	if !trusted {
+		for key := range req.Header {
+			if isSensitiveHeader(key, custom) {
+				req.Header.Del(key)
+			}
+		}
+	}
 	return nil
 }
 
+// headersKey is the request context key holding the headers
+// set with SetHeader on the reference that made the request.
+type headersKey struct{}
+
+// isSensitiveHeader reports whether the header may hold credentials, which
+// is the case of the headers set with SetHeader, such as the credentials of
+// a gateway, but the User-Agent.
+func isSensitiveHeader(key string, custom http.Header) bool {
+	if sensitiveHeaders[key] {
+		return true
+	}
+	_, ok := custom[key]
+	return ok && key != userAgentHeader
+}
+
+// sensitiveHeaders are the headers holding credentials,
+// they are stripped from cross-origin redirects.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+}
+
+// firebaseDomains are the domains redirects between
+// which keep their credentials.
+var firebaseDomains = []string{".firebaseio.com", ".firebasedatabase.app"}
+
+// trustedRedirect reports whether the credentials sent to from can be sent
+// along a redirect to to, that is if both use the same scheme and are the
+// same host or Firebase hosts.
+func trustedRedirect(from, to *_url.URL) bool {
+	if from.Scheme != to.Scheme {
+		return false
+	}
+	if from.Host == to.Host {
+		return true
+	}
+	return isFirebaseHost(from.Hostname()) && isFirebaseHost(to.Hostname())
+}
+
+func isFirebaseHost(host string) bool {
+	host = strings.ToLower(host)
+	for _, domain := range firebaseDomains {
+		if strings.HasSuffix(host, domain) {
+			return true
+		}
+	}
+	return false
+}
+
 func withHeader(key, value string) func(*http.Request) {
 	return func(req *http.Request) {
 		req.Header.Add(key, value)
 	}
 }
 
-func (fb *Firebase) doRequest(method string, body []byte, options ...func(*http.Request)) (http.Header, []byte, error) {
-	req, err := http.NewRequest(method, fb.String(), bytes.NewReader(body))
-	if err != nil {
+func withParam(key, value string) func(*http.Request) {
+	return func(req *http.Request) {
+		query := req.URL.Query()
+		query.Set(key, value)
+		req.URL.RawQuery = query.Encode()
+	}
+}
+
+// contextError wraps the error of a canceled or expired context so that
+// callers can tell it apart from an ErrTimeout.
+func contextError(ctx context.Context) error {
+	return fmt.Errorf("request aborted: %w", ctx.Err())
+}
+
+func (fb *Firebase) doRequest(ctx context.Context, method string, body []byte, options ...func(*http.Request)) (http.Header, []byte, error) {
+	if err := fb.checkWriteSize(body); err != nil {
 		return nil, nil, err
 	}
+	if dryRun, hooks := fb.getDryRun(); dryRun && method != "GET" {
+		return fb.skipRequest(ctx, method, body, hooks, options...)
+	}
+	if len(options) == 0 {
+		var (
+			headers  http.Header
+			respBody []byte
+		)
+		queued, err := fb.queueWrite(ctx, method, body, func() (err error) {
+			headers, respBody, err = fb.doRequest(context.WithValue(ctx, flushKey{}, true), method, body)
+			return err
+		})
+		if queued {
+			return headers, respBody, err
+		}
+	}
+
+	var cacheGeneration uint64
+	cached := fb.cache != nil && method == "GET" && len(options) == 0
+	if cached {
+		if headers, data, ok := fb.cache.get(fb.String()); ok {
+			return headers, data, nil
+		}
+		cacheGeneration = fb.cache.generation()
+	}
+
+	if fb.getCompression() {
+		options = append(options, withHeader("Accept-Encoding", "gzip"))
+		if len(body) > 0 {
+			compressed, opts, err := compressBody(body)
+			if err != nil {
+				return nil, nil, err
+			}
+			body, options = compressed, append(options, opts...)
+		}
+	}
+
+	attempts := 1
+	maxAttempts, backoff := fb.getRetry()
+	if maxAttempts > 1 && isIdempotent(method) {
+		attempts = maxAttempts
+	}
+
+	var (
+		headers  http.Header
+		respBody []byte
+		err      error
+	)
+	defer fb.reportTiming(method, time.Now())
+	for attempt := 0; attempt < attempts; attempt++ {
+		if delay := retryDelay(attempt, backoff, err); delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return nil, nil, contextError(ctx)
+			}
+		}
+
+		headers, respBody, err = fb.do(ctx, method, body, options...)
+		if !isTransient(err) {
+			break
+		}
+	}
+	if err == nil && method == "GET" {
+		respBody, err = fb.filterKeyRange(respBody)
+	}
+	if err == nil && cached {
+		fb.cache.set(cacheGeneration, fb.String(), headers, respBody)
+	}
+	return headers, respBody, err
+}
+
+// retryDelay returns how long to wait before the given attempt of a request
+// whose previous attempt failed with err, that is the delay of the given
+// backoff, or the delay asked by Firebase when it throttled the request if
+// it is longer.
+func retryDelay(attempt int, backoff func(attempt int) time.Duration, err error) time.Duration {
+	if attempt == 0 {
+		return 0
+	}
+	var delay time.Duration
+	if backoff != nil {
+		delay = backoff(attempt)
+	}
+	var fbErr *FirebaseError
+	if errors.As(err, &fbErr) && fbErr.RetryAfter > delay {
+		delay = fbErr.RetryAfter
+	}
+	return delay
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+// isTransient reports whether a request that failed with
+// the given error is worth retrying.
+func isTransient(err error) bool {
+	switch err := err.(type) {
+	case ErrTimeout:
+		return true
+	case *_url.Error, net.Error:
+		return isConnectionError(err)
+	case *FirebaseError:
+		return err.StatusCode >= 500 || err.throttled()
+	}
+	return false
+}
+
+// isConnectionError reports whether err is a network failure that may not
+// happen again, such as a refused or reset connection. The errors of the
+// client, such as TLS or redirect errors, are wrapped in a *url.Error that
+// is a net.Error as well, only the error it wraps is considered.
+func isConnectionError(err error) bool {
+	var urlErr *_url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// newRequest builds a request for the current reference, including
+// any credentials that have to be fetched at request time.
+func (fb *Firebase) newRequest(ctx context.Context, method string, body io.Reader) (*http.Request, error) {
+	if fb.pathErr != nil {
+		// reported here since Child cannot return an error
+		return nil, fb.pathErr
+	}
+
+	if method == "GET" {
+		// queries only apply to reads, catch the combinations Firebase
+		// rejects before making a request that is bound to fail
+		fb.paramsMtx.RLock()
+		err := validateQuery(fb.params)
+		fb.paramsMtx.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := fb.waitRateLimit(ctx); err != nil {
+		return nil, err
+	}
+
+	fb.paramsMtx.RLock()
+	headers := fb.headers.Clone()
+	mode := fb.authMode
+	fb.paramsMtx.RUnlock()
+
+	ctx = context.WithValue(ctx, timeoutKey{}, fb.getTimeout())
+	ctx = context.WithValue(ctx, redirectLimitKey{}, fb.getRedirectLimit())
+	ctx = context.WithValue(ctx, headersKey{}, headers)
+	req, err := http.NewRequestWithContext(ctx, method, fb.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	if ts := fb.getTokenSource(); ts != nil {
+		token, err := ts.Token()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get access token. %s", err)
+		}
+		withParam(accessTokenParam, token)(req)
+	}
+
+	for key, values := range headers {
+		req.Header[key] = append([]string(nil), values...)
+	}
+	if mode == AuthHeader {
+		moveAuthToHeader(req)
+	}
+	return req, nil
+}
+
+// moveAuthToHeader removes the credentials from the request URL and
+// sends them as a bearer token instead.
+func moveAuthToHeader(req *http.Request) {
+	query := req.URL.Query()
+	token := query.Get(accessTokenParam)
+	if token == "" {
+		token = query.Get(authParam)
+	}
+	query.Del(accessTokenParam)
+	query.Del(authParam)
+	req.URL.RawQuery = query.Encode()
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// DoRaw sends a request with the given method and body to the Firebase
+// reference and returns the response as is, whatever its status code.
+// The response body is neither read nor decompressed and requests are never
+// retried, which allows large payloads to be streamed.
+//
+// The caller must close the response body when done with it. In dry-run
+// mode, see SetDryRun, requests other than GET get the response of a
+// successful write without being sent.
+func (fb *Firebase) DoRaw(method string, body []byte, opts ...func(*http.Request)) (*http.Response, error) {
+	if dryRun, hooks := fb.getDryRun(); dryRun && method != "GET" {
+		headers, data, err := fb.skipRequest(context.Background(), method, body, hooks, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return dryRunResponse(headers, data), nil
+	}
+	return fb.send(context.Background(), method, bytes.NewReader(body), opts...)
+}
+
+// send performs a single request and returns the response
+// without reading its body.
+func (fb *Firebase) send(ctx context.Context, method string, body io.Reader, options ...func(*http.Request)) (*http.Response, error) {
+	if fb.cache != nil && method != "GET" {
+		// invalidated again once done, in case a read
+		// made during the write cached the old value
+		fb.cache.invalidate()
+		defer fb.cache.invalidate()
+	}
+
+	req, err := fb.newRequest(ctx, method, body)
+	if err != nil {
+		return nil, err
+	}
 
 	for _, opt := range options {
 		opt(req)
 	}
 
-	resp, err := fb.client.Do(req)
+	requestHooks, responseHooks := fb.getHooks()
+	for _, hook := range requestHooks {
+		hook(req)
+	}
+
+	start := time.Now()
+	resp, err := fb.roundTrip(ctx, req)
+	if len(responseHooks) > 0 {
+		info := RequestInfo{
+			Method:   req.Method,
+			URL:      redactURL(req.URL),
+			Duration: time.Since(start),
+			Err:      err,
+		}
+		if resp != nil {
+			info.StatusCode = resp.StatusCode
+		}
+		for _, hook := range responseHooks {
+			hook(info)
+		}
+	}
+	return resp, err
+}
+
+func (fb *Firebase) roundTrip(ctx context.Context, req *http.Request) (*http.Response, error) {
+	client, defaultClient := fb.getClient()
+	if !defaultClient {
+		return clientDo(ctx, client, req)
+	}
+
+	// the timeout covers establishing the connection and receiving the
+	// response headers. It is enforced per request rather than by the
+	// shared transport so that concurrent requests do not interfere.
+	reqCtx, cancel := context.WithCancel(req.Context())
+	timer := time.AfterFunc(fb.requestTimeout(reqCtx), cancel)
+	resp, err := clientDo(ctx, client, req.WithContext(reqCtx))
+	if !timer.Stop() && ctx.Err() == nil {
+		// the timer fired before the response headers were received,
+		// the error of the request it canceled is context.Canceled
+		cancel()
+		if err == nil {
+			resp.Body.Close()
+		}
+		if _, ok := err.(ErrTimeout); !ok {
+			err = ErrTimeout{errHeaderTimeout}
+		}
+		return nil, err
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// reading the body is not limited, the request context
+	// is only released once the body is closed
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// errHeaderTimeout is wrapped in an ErrTimeout when a request does not
+// establish its connection and receive the response headers in time.
+var errHeaderTimeout = errors.New("timeout awaiting the connection or the response headers")
+
+// cancelOnClose releases the context of a request when its body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+func clientDo(ctx context.Context, client *http.Client, req *http.Request) (*http.Response, error) {
+	resp, err := client.Do(req)
+	if err != nil && ctx.Err() != nil {
+		// the caller gave up on the request, this is not a timeout
+		return nil, contextError(ctx)
+	}
+
 	switch err := err.(type) {
 	default:
-		return nil, nil, err
+		return nil, err
 	case nil:
-		// carry on
+		return resp, nil
 
 	case *_url.Error:
 		// `http.Client.Do` will return a `url.Error` that wraps a `net.Error`
 		// when exceeding it's `Transport`'s `ResponseHeadersTimeout`
 		e1, ok := err.Err.(net.Error)
 		if ok && e1.Timeout() {
-			return nil, nil, ErrTimeout{err}
+			return nil, ErrTimeout{err}
 		}
 
-		return nil, nil, err
+		return nil, err
 
 	case net.Error:
 		// `http.Client.Do` will return a `net.Error` directly when Dial times
 		// out, or when the Client's RoundTripper otherwise returns an err
 		if err.Timeout() {
-			return nil, nil, ErrTimeout{err}
+			return nil, ErrTimeout{err}
 		}
 
+		return nil, err
+	}
+}
+
+func (fb *Firebase) do(ctx context.Context, method string, body []byte, options ...func(*http.Request)) (http.Header, []byte, error) {
+	resp, err := fb.send(ctx, method, bytes.NewReader(body), options...)
+	if err != nil {
 		return nil, nil, err
 	}
 
-	defer resp.Body.Close()
-	respBody, err := ioutil.ReadAll(resp.Body)
+	defer closeBody(resp.Body)
+	bodyReader, err := decompressBody(resp)
 	if err != nil {
 		return nil, nil, err
 	}
-	if resp.StatusCode/200 != 1 {
-		return resp.Header, respBody, errors.New(string(respBody))
+
+	respBody, err := fb.readBody(bodyReader)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, nil, contextError(ctx)
+		}
+		return nil, nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		// only sent in response to a conditional read
+		return resp.Header, nil, ErrNotModified
+	}
+	if resp.StatusCode/100 != 2 {
+		fbErr := newFirebaseError(resp.StatusCode, resp.Header, respBody)
+		if resp.StatusCode == http.StatusBadRequest {
+			fb.checkIndexWarning(fbErr)
+		}
+		return resp.Header, respBody, fbErr
 	}
 	return resp.Header, respBody, nil
 }
@@ -1,16 +1,30 @@
 package firego
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	_url "net/url"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/trevor403/firego/firetest"
+	"golang.org/x/oauth2"
 )
 
 const URL = "https://somefirebaseapp.firebaseIO.com"
@@ -46,6 +60,118 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestNew_Regional(t *testing.T) {
+	t.Parallel()
+	for _, url := range []string{
+		"mydb.europe-west1.firebasedatabase.app",
+		"https://mydb.europe-west1.firebasedatabase.app/",
+	} {
+		fb := New(url, nil)
+		assert.Equal(t, "https://mydb.europe-west1.firebasedatabase.app", fb.URL(), "givenURL: %s", url)
+		assert.Equal(t, "https://mydb.europe-west1.firebasedatabase.app/users/.json", fb.Child("users").String(), "givenURL: %s", url)
+	}
+}
+
+func TestNewDatabase(t *testing.T) {
+	t.Parallel()
+	for region, expected := range map[string]string{
+		"":                "https://my-db.firebaseio.com",
+		"us-central1":     "https://my-db.firebaseio.com",
+		"europe-west1":    "https://my-db.europe-west1.firebasedatabase.app",
+		"asia-southeast1": "https://my-db.asia-southeast1.firebasedatabase.app",
+	} {
+		fb, err := NewDatabase("my-db", region, nil)
+		require.NoError(t, err, "region: %s", region)
+		assert.Equal(t, expected, fb.URL(), "region: %s", region)
+	}
+
+	for _, database := range [][2]string{
+		{"", "europe-west1"},
+		{"my.db", ""},
+		{"MyDB", ""},
+		{"-mydb", ""},
+		{"mydb", "europe/west1"},
+		{"mydb", "europe-west1-"},
+	} {
+		_, err := NewDatabase(database[0], database[1], nil)
+		assert.Error(t, err, "database: %v", database)
+	}
+}
+
+func TestNew_Emulator(t *testing.T) {
+	t.Parallel()
+	for _, url := range []string{
+		"http://localhost:9000/?ns=myproject",
+		"http://localhost:9000?ns=myproject",
+		"localhost:9000/?ns=myproject",
+	} {
+		fb := New(url, nil)
+		assert.Equal(t, "http://localhost:9000", fb.url, "givenURL: %s", url)
+		assert.Equal(t, "http://localhost:9000/users/.json?ns=myproject", fb.Child("users").String(), "givenURL: %s", url)
+	}
+
+	server := newTestServer("")
+	defer server.Close()
+
+	fb := New(server.URL+"/?ns=myproject", nil)
+	fb.Child("users").Value("")
+	ref, err := fb.Child("users").Ref("other")
+	require.NoError(t, err)
+	ref.Value("")
+	require.Len(t, server.receivedReqs, 2)
+	for _, req := range server.receivedReqs {
+		assert.Equal(t, "myproject", req.URL.Query().Get(nsParam))
+	}
+	assert.Equal(t, "/other/.json", server.receivedReqs[1].URL.Path)
+}
+
+func TestSetInsecure(t *testing.T) {
+	t.Parallel()
+	server := newTestServer("null")
+	defer server.Close()
+
+	// the emulator host name resolves to the test server
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+		},
+	}}
+	fb := New("emulator.internal:9000", client)
+	assert.Equal(t, "https://emulator.internal:9000", fb.URL())
+
+	fb.SetInsecure(true)
+	assert.Equal(t, "http://emulator.internal:9000", fb.URL())
+	require.NoError(t, fb.Child("users").Value(new(interface{})))
+	require.Len(t, server.receivedReqs, 1)
+	assert.Equal(t, "/users/.json", server.receivedReqs[0].URL.Path)
+
+	fb.SetInsecure(false)
+	assert.Equal(t, "https://emulator.internal:9000", fb.URL())
+}
+
+func TestNew_QueryParams(t *testing.T) {
+	t.Parallel()
+	server := newTestServer("")
+	defer server.Close()
+
+	fb := New(server.URL+"/base/?proxy=1&tag=a&tag=b", nil)
+	assert.Equal(t, server.URL+"/base", fb.url)
+	fb.Auth("token")
+	fb.OrderByKey().LimitToFirst(2).Child("child").Value("")
+	require.Len(t, server.receivedReqs, 1)
+
+	req := server.receivedReqs[0]
+	assert.Equal(t, "/base/child/.json", req.URL.Path)
+	assert.Equal(t, _url.Values{
+		"proxy":           {"1"},
+		"tag":             {"a", "b"},
+		authParam:         {"token"},
+		orderByParam:      {`"$key"`},
+		limitToFirstParam: {"2"},
+	}, req.URL.Query())
+	assert.Equal(t, 1, strings.Count(fb.String(), "?"))
+}
+
 func TestNewWithProvidedHttpClient(t *testing.T) {
 	t.Parallel()
 
@@ -64,6 +190,32 @@ func TestNewWithProvidedHttpClient(t *testing.T) {
 	}
 }
 
+func TestSetClient(t *testing.T) {
+	t.Parallel()
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		w.Write([]byte(`"foo"`))
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	client := &http.Client{Transport: http.DefaultTransport}
+	fb.SetClient(client)
+	child := fb.Child("child")
+	assert.Equal(t, client, child.client)
+	assert.False(t, child.defaultClient)
+
+	var v string
+	require.NoError(t, child.Value(&v))
+	assert.Equal(t, "foo", v)
+	assert.Equal(t, 1, requests)
+
+	fb.SetClient(nil)
+	assert.NotEqual(t, client, fb.client)
+	assert.True(t, fb.defaultClient)
+}
+
 func TestAuth(t *testing.T) {
 	t.Parallel()
 	server := firetest.New()
@@ -186,6 +338,55 @@ func TestValue(t *testing.T) {
 	assert.Equal(t, response, v)
 }
 
+func TestValueInto(t *testing.T) {
+	t.Parallel()
+	server := firetest.New()
+	server.Start()
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	server.Set("zero", 0)
+
+	v := 42
+	found, err := fb.Child("missing").ValueInto(&v)
+	require.NoError(t, err)
+	assert.False(t, found)
+	assert.Equal(t, 42, v)
+
+	found, err = fb.Child("zero").ValueInto(&v)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, 0, v)
+
+	exists, err := fb.Child("missing").Exists()
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	exists, err = fb.Child("zero").Exists()
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestExists_Shallow(t *testing.T) {
+	t.Parallel()
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		queries = append(queries, req.URL.RawQuery)
+		w.Write([]byte(`{"a":true}`))
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	exists, err := fb.Exists()
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	// shallow cannot be used along with a query
+	_, err = fb.OrderBy("age").LimitToFirst(1).Exists()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"shallow=true", "limitToFirst=1&orderBy=%22age%22"}, queries)
+}
+
 func TestChild(t *testing.T) {
 	t.Parallel()
 	var (
@@ -197,6 +398,357 @@ func TestChild(t *testing.T) {
 	assert.Equal(t, fmt.Sprintf("%s/%s", parent.url, childNode), child.url)
 }
 
+func TestChild_Slashes(t *testing.T) {
+	t.Parallel()
+	parent := New(URL, nil)
+	for child, expected := range map[string]string{
+		"":             URL,
+		"/":            URL,
+		"/foo":         URL + "/foo",
+		"foo/":         URL + "/foo",
+		"//foo//bar//": URL + "/foo/bar",
+		"foo/bar/baz":  URL + "/foo/bar/baz",
+	} {
+		c := parent.Child(child)
+		assert.Equal(t, expected, c.url, "child: %q", child)
+		assert.NoError(t, c.pathErr, "child: %q", child)
+
+		ref, err := parent.Ref(child)
+		require.NoError(t, err, "path: %q", child)
+		assert.Equal(t, expected, strings.TrimSuffix(ref.url, "/"), "path: %q", child)
+	}
+	assert.Equal(t, URL+"/foo/bar", parent.Child("foo/").Child("/bar").url)
+}
+
+func TestParent(t *testing.T) {
+	t.Parallel()
+	fb := New(URL, nil)
+	fb.SetAuthMode(AuthHeader)
+
+	parent := fb.Child("users/alice smith/posts").Parent()
+	require.NotNil(t, parent)
+	assert.Equal(t, URL+"/users/alice%20smith", parent.url)
+	assert.Equal(t, AuthHeader, parent.authMode)
+
+	parent = parent.Parent().Parent()
+	require.NotNil(t, parent)
+	assert.Equal(t, URL, parent.url)
+	assert.Nil(t, parent.Parent())
+
+	root, err := fb.Ref("")
+	require.NoError(t, err)
+	assert.Nil(t, root.Parent())
+
+	// the invalid key is not part of the parent
+	parent = fb.Child("users/a.b").Parent()
+	assert.NoError(t, parent.pathErr)
+	assert.Equal(t, URL+"/users", parent.url)
+}
+
+func TestKey(t *testing.T) {
+	t.Parallel()
+	fb := New(URL, nil)
+	assert.Equal(t, "", fb.Key())
+	assert.Equal(t, "users", fb.Child("users").Key())
+	assert.Equal(t, "alice smith", fb.Child("users/alice smith").Key())
+	assert.Equal(t, "users", fb.Child("users/alice").Parent().Key())
+
+	root, err := fb.Ref("/")
+	require.NoError(t, err)
+	assert.Equal(t, "", root.Key())
+}
+
+func TestRoot(t *testing.T) {
+	t.Parallel()
+	server := newTestServer("null")
+	defer server.Close()
+
+	fb := New(server.URL+"/?ns=myproject", nil)
+	fb.Auth("token")
+	fb.SetHeader("X-Custom", "value")
+
+	root := fb.Child("users/a.b").Root()
+	assert.Equal(t, server.URL, root.URL())
+	assert.Equal(t, "", root.Key())
+	assert.Nil(t, root.Parent())
+	require.NoError(t, root.Value(new(interface{})))
+
+	require.Len(t, server.receivedReqs, 1)
+	req := server.receivedReqs[0]
+	assert.Equal(t, "/.json", req.URL.Path)
+	assert.Equal(t, "token", req.URL.Query().Get(authParam))
+	assert.Equal(t, "myproject", req.URL.Query().Get(nsParam))
+	assert.Equal(t, "value", req.Header.Get("X-Custom"))
+}
+
+func TestClone(t *testing.T) {
+	t.Parallel()
+	fb := New(URL, nil).Child("users")
+	fb.Auth("token")
+	fb.params.Add("custom", "a")
+	fb.SetHeader("X-Custom", "value")
+
+	clone := fb.Clone()
+	assert.Equal(t, fb.String(), clone.String())
+	assert.Equal(t, "value", clone.headers.Get("X-Custom"))
+
+	clone.Auth("other")
+	clone.params.Add("custom", "b")
+	clone.SetHeader("X-Custom", "other")
+	clone = clone.OrderBy("age")
+	assert.Equal(t, "token", fb.params.Get(authParam))
+	assert.Equal(t, []string{"a"}, fb.params["custom"])
+	assert.Equal(t, "", fb.params.Get(orderByParam))
+	assert.Equal(t, "value", fb.headers.Get("X-Custom"))
+
+	assert.NotEqual(t, fb.stopWatching, clone.stopWatching)
+	fb.StopWatching()
+	select {
+	case <-clone.stopWatching:
+		t.Fatal("stopping the original stopped the watches of the clone")
+	default:
+	}
+}
+
+func TestWithAuth(t *testing.T) {
+	t.Parallel()
+	tokens := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		tokens <- req.URL.Query().Get(authParam) + "|" + req.URL.Query().Get(accessTokenParam)
+		fmt.Fprint(w, "null")
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	fb.Auth("admin")
+	fb.SetTokenSource(TokenSourceFunc(func() (string, error) { return "access", nil }))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, fb.WithAuth(fmt.Sprint("user", i)).Value(new(interface{})))
+		}(i)
+	}
+	wg.Wait()
+	require.NoError(t, fb.Value(new(interface{})))
+	close(tokens)
+
+	var received []string
+	for token := range tokens {
+		received = append(received, token)
+	}
+	assert.ElementsMatch(t, []string{"user0|", "user1|", "user2|", "user3|", "user4|", "admin|access"}, received)
+	assert.Equal(t, "admin", fb.params.Get(authParam))
+}
+
+func TestChild_ConcurrentConfig(t *testing.T) {
+	t.Parallel()
+	// meant for the race detector, setters must not race with copy
+	// or with the requests that read the settings
+	server := newTestServer("null")
+	defer server.Close()
+	fb := New(server.URL, nil)
+	setters := []func(){
+		func() { fb.SetWatchReconnect(true) },
+		func() { fb.SetWatchKeepAlive(true) },
+		func() { fb.SetWatchReconnectBackoff(time.Second, time.Minute, 0.5) },
+		func() { fb.OnWatchReconnect(func(int, time.Duration) {}) },
+		func() { fb.SetRetry(2, nil) },
+		func() { fb.SetTransactionRetry(2, nil) },
+		func() { fb.SetCompression(true) },
+		func() { fb.SetDecoderOption((*json.Decoder).UseNumber) },
+		func() { fb.SetMarshalFunc(json.Marshal) },
+		func() { fb.SetUnmarshalFunc(json.Unmarshal) },
+	}
+
+	var wg sync.WaitGroup
+	for _, set := range setters {
+		wg.Add(1)
+		go func(set func()) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				set()
+			}
+		}(set)
+	}
+	for i := 0; i < 10; i++ {
+		fb.Child("child")
+		assert.NoError(t, fb.Value(new(interface{})))
+		assert.NoError(t, fb.Set(true))
+	}
+	wg.Wait()
+}
+
+func TestChild_Config(t *testing.T) {
+	t.Parallel()
+	var (
+		backoff = func(int) time.Duration { return time.Millisecond }
+		hook    = func(*http.Request) {}
+	)
+	parent := New(URL, &http.Client{})
+	parent.SetTimeout(time.Minute)
+	parent.SetSharedAuth(NewAuth("token"))
+	parent.SetTokenSource(TokenSourceFunc(func() (string, error) { return "", nil }))
+	parent.SetAuthMode(AuthHeader)
+	parent.watchHeartbeat = time.Hour
+	parent.SetWatchReconnect(true)
+	parent.SetWatchKeepAlive(true)
+	parent.watchReconnectDelay = time.Hour
+	parent.SetWatchReconnectBackoff(time.Hour, 2*time.Hour, 0.5)
+	parent.OnWatchReconnect(func(int, time.Duration) {})
+	parent.SetTransactionRetry(3, backoff)
+	parent.SetRetry(4, backoff)
+	parent.SetDecoderOption((*json.Decoder).UseNumber)
+	parent.SetCompression(true)
+	parent.Auth("secret")
+	parent = parent.PageSize(5)
+	parent.OnRequest(hook)
+	parent.OnResponse(func(RequestInfo) {})
+	parent.OnTiming(func(string, time.Duration) {})
+	parent.SetMultiConcurrency(3)
+	parent.SetHeader("X-Client-Version", "1")
+	parent.SetRedirectLimit(2)
+	parent.SetDryRun(true)
+	parent.OnDryRun(func(DryRunRequest) {})
+	parent = parent.StartAtKey(1, "a").EndAtKey(2, "b")
+	parent.SetMarshalFunc(json.Marshal)
+	parent.SetUnmarshalFunc(json.Unmarshal)
+	parent.SetMaxWriteSize(1024)
+	parent.SetMaxResponseBytes(2048)
+	parent.SetRateLimit(10, 2)
+	parent.SetIndexWarningHandler(func(string, string) {})
+	parent.SetOfflineQueue(NewMemoryQueue())
+
+	child := parent.Child("child")
+	assert.Equal(t, parent.client, child.client)
+	assert.Equal(t, parent.clientTimeout, child.clientTimeout)
+	assert.Equal(t, parent.defaultClient, child.defaultClient)
+	assert.Equal(t, parent.sharedAuth, child.sharedAuth)
+	assert.NotNil(t, child.tokenSource)
+	assert.Equal(t, parent.authMode, child.authMode)
+	assert.Equal(t, parent.watchHeartbeat, child.watchHeartbeat)
+	assert.Equal(t, parent.watchReconnect, child.watchReconnect)
+	assert.Equal(t, parent.watchKeepAlive, child.watchKeepAlive)
+	assert.Equal(t, parent.watchReconnectDelay, child.watchReconnectDelay)
+	assert.Equal(t, parent.watchReconnectMax, child.watchReconnectMax)
+	assert.Equal(t, parent.watchReconnectJitter, child.watchReconnectJitter)
+	assert.NotNil(t, child.watchReconnectHook)
+	assert.Equal(t, parent.transactionAttempts, child.transactionAttempts)
+	assert.NotNil(t, child.transactionBackoff)
+	assert.Equal(t, parent.retryAttempts, child.retryAttempts)
+	assert.NotNil(t, child.retryBackoff)
+	assert.Len(t, child.decoderOptions, 1)
+	assert.Equal(t, parent.compression, child.compression)
+	assert.Equal(t, parent.params, child.params)
+	assert.Equal(t, parent.pageSize, child.pageSize)
+	assert.Len(t, child.requestHooks, 1)
+	assert.Len(t, child.responseHooks, 1)
+	assert.Len(t, child.timingHooks, 1)
+	assert.Equal(t, parent.multiConcurrency, child.multiConcurrency)
+	assert.Equal(t, parent.headers, child.headers)
+	assert.Equal(t, parent.redirectLimit, child.redirectLimit)
+	assert.Equal(t, parent.dryRun, child.dryRun)
+	assert.Len(t, child.dryRunHooks, 1)
+	assert.Equal(t, parent.startAtKey, child.startAtKey)
+	assert.Equal(t, parent.endAtKey, child.endAtKey)
+	assert.NotNil(t, child.marshalFunc)
+	assert.NotNil(t, child.unmarshalFunc)
+	assert.Equal(t, parent.maxWriteSize, child.maxWriteSize)
+	assert.Equal(t, parent.maxResponseBytes, child.maxResponseBytes)
+	assert.Same(t, parent.limiter, child.limiter)
+	assert.NotNil(t, child.indexWarningHandler)
+	assert.Same(t, parent.queue, child.queue)
+
+	// fields that hold the state of a single reference rather than
+	// configuration, any other field must be checked above
+	state := map[string]bool{
+		"url": true, "pathErr": true, "paramsMtx": true, "eventMtx": true, "eventFuncs": true,
+		"watchMtx": true, "watching": true, "stopWatching": true, "watchDone": true, "watchForwardDone": true, "cache": true,
+	}
+	checked := map[string]bool{
+		"client": true, "clientTimeout": true, "defaultClient": true, "sharedAuth": true, "tokenSource": true,
+		"authMode": true, "params": true, "watchHeartbeat": true, "watchReconnect": true, "watchKeepAlive": true,
+		"watchReconnectDelay": true, "watchReconnectMax": true, "watchReconnectJitter": true, "watchReconnectHook": true, "transactionAttempts": true, "transactionBackoff": true,
+		"retryAttempts": true, "retryBackoff": true, "decoderOptions": true,
+		"compression": true, "pageSize": true, "requestHooks": true, "responseHooks": true, "timingHooks": true,
+		"multiConcurrency": true, "headers": true, "redirectLimit": true,
+		"dryRun": true, "dryRunHooks": true, "startAtKey": true, "endAtKey": true,
+		"marshalFunc": true, "unmarshalFunc": true, "maxWriteSize": true,
+		"maxResponseBytes": true, "limiter": true,
+		"indexWarningHandler": true, "queue": true,
+	}
+	typ := reflect.TypeOf(Firebase{})
+	for i := 0; i < typ.NumField(); i++ {
+		name := typ.Field(i).Name
+		assert.True(t, state[name] || checked[name], "field %s is not covered by copy", name)
+	}
+}
+
+func TestSetTLSConfig(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `true`)
+	}))
+	defer server.Close()
+
+	var (
+		fb = New(server.URL, nil)
+		v  bool
+	)
+	// the certificate of the test server is not trusted by default
+	assert.Error(t, fb.Value(&v))
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	require.NoError(t, fb.SetTLSConfig(&tls.Config{RootCAs: pool}))
+	require.NoError(t, fb.Child("child").Value(&v))
+	assert.True(t, v)
+
+	assert.Error(t, New(server.URL, server.Client()).SetTLSConfig(&tls.Config{}))
+}
+
+func TestSetTransportOptions(t *testing.T) {
+	t.Parallel()
+	fb := New(URL, nil)
+	tr := fb.client.Transport.(*http.Transport)
+	assert.Equal(t, defaultMaxIdleConnsPerHost, tr.MaxIdleConnsPerHost)
+
+	err := fb.Child("child").SetTransportOptions(func(tr *http.Transport) {
+		tr.MaxIdleConnsPerHost = 7
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 7, tr.MaxIdleConnsPerHost)
+
+	fb = New(URL, &http.Client{})
+	assert.Error(t, fb.SetTransportOptions(func(*http.Transport) {}))
+}
+
+func TestChild_Escaping(t *testing.T) {
+	t.Parallel()
+	server := firetest.New()
+	server.Start()
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	for _, key := range []string{"with space", "question?mark", "per%cent", "a&b=c", "ünïcode"} {
+		child := fb.Child("users/" + key)
+		assert.True(t, strings.HasPrefix(child.URL(), server.URL+"/users/"), child.URL())
+		require.NoError(t, child.Set(key))
+
+		ref, err := fb.Ref("/users/" + key + "/")
+		require.NoError(t, err)
+		assert.Equal(t, child.URL(), ref.URL())
+
+		var v string
+		require.NoError(t, ref.Value(&v))
+		assert.Equal(t, key, v)
+	}
+	assert.Equal(t, server.URL+"/users/with%20space", fb.Child("users/with space").URL())
+	assert.Equal(t, server.URL+"/users/question%3Fmark", fb.Child("users/question?mark").URL())
+}
+
 func TestChild_Issue26(t *testing.T) {
 	t.Parallel()
 	parent := New(URL, nil)
@@ -222,12 +774,61 @@ func TestTimeoutDuration_Headers(t *testing.T) {
 	<-done
 	assert.NotNil(t, err)
 	assert.IsType(t, ErrTimeout{}, err)
+	assert.EqualError(t, err, "timeout awaiting the connection or the response headers")
+	assert.False(t, errors.Is(err, context.Canceled), "%v", err)
 
-	// ResponseHeaderTimeout should be TimeoutDuration less the time it took to dial, and should be positive
+	// the timeout is enforced per request, the shared transport is left untouched
 	require.IsType(t, (*http.Transport)(nil), fb.client.Transport)
-	tr := fb.client.Transport.(*http.Transport)
-	assert.True(t, tr.ResponseHeaderTimeout < TimeoutDuration)
-	assert.True(t, tr.ResponseHeaderTimeout > 0)
+	assert.Zero(t, fb.client.Transport.(*http.Transport).ResponseHeaderTimeout)
+}
+
+func TestTimeoutDuration_Body(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte(`"foo"`))
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	fb.SetTimeout(10 * time.Millisecond)
+
+	// the timeout does not apply once the headers are received
+	var v string
+	require.NoError(t, fb.Value(&v))
+	assert.Equal(t, "foo", v)
+}
+
+func TestTimeoutDuration_Concurrent(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte(`"foo"`))
+	}))
+	defer server.Close()
+
+	var (
+		fb    = New(server.URL, nil)
+		short = fb.Child("short")
+		wg    sync.WaitGroup
+	)
+	short.SetTimeout(time.Millisecond)
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			var v string
+			assert.NoError(t, fb.Value(&v))
+		}()
+		go func() {
+			defer wg.Done()
+			var v string
+			assert.IsType(t, ErrTimeout{}, short.Value(&v))
+		}()
+	}
+	wg.Wait()
 }
 
 func TestTimeoutDuration_Dial(t *testing.T) {
@@ -238,7 +839,752 @@ func TestTimeoutDuration_Dial(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.IsType(t, ErrTimeout{}, err)
 
-	// ResponseHeaderTimeout should be negative since the total duration was consumed when dialing
-	require.IsType(t, (*http.Transport)(nil), fb.client.Transport)
-	assert.True(t, fb.client.Transport.(*http.Transport).ResponseHeaderTimeout < 0)
+}
+
+func TestSetTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte(`"foo"`))
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	child := fb.Child("child")
+	child.SetTimeout(time.Millisecond)
+	assert.Equal(t, TimeoutDuration, fb.getTimeout())
+
+	var v string
+	err := child.Value(&v)
+	assert.IsType(t, ErrTimeout{}, err)
+
+	require.NoError(t, fb.Value(&v))
+	assert.Equal(t, "foo", v)
+}
+
+func TestTimeoutDuration_Live(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte(`"foo"`))
+	}))
+	defer server.Close()
+
+	defaultTimeout := TimeoutDuration
+	defer func() { TimeoutDuration = defaultTimeout }()
+
+	fb := New(server.URL, nil)
+	var v string
+	require.NoError(t, fb.Value(&v))
+
+	TimeoutDuration = time.Millisecond
+	err := fb.Value(&v)
+	assert.IsType(t, ErrTimeout{}, err)
+
+	TimeoutDuration = defaultTimeout
+	require.NoError(t, fb.Value(&v))
+	assert.Equal(t, "foo", v)
+}
+
+func TestValueWithContext_Canceled(t *testing.T) {
+	t.Parallel()
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	fb := New(server.URL, nil)
+	err := fb.ValueWithContext(ctx, new(interface{}))
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.NotEqual(t, reflect.TypeOf(ErrTimeout{}), reflect.TypeOf(err))
+}
+
+func TestSetWithContext_Deadline(t *testing.T) {
+	t.Parallel()
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	fb := New(server.URL, nil)
+	err := fb.SetWithContext(ctx, true)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.NotEqual(t, reflect.TypeOf(ErrTimeout{}), reflect.TypeOf(err))
+}
+
+func TestSilentWrites(t *testing.T) {
+	t.Parallel()
+	var (
+		server = newTestServer("")
+		fb     = New(server.URL, nil)
+	)
+	defer server.Close()
+
+	fb.Auth(authToken)
+	require.NoError(t, fb.SetSilent(true))
+	require.NoError(t, fb.UpdateSilent(map[string]bool{"foo": true}))
+	require.NoError(t, fb.PushSilent(true))
+	require.Len(t, server.receivedReqs, 3)
+
+	for i, method := range []string{"PUT", "PATCH", "POST"} {
+		req := server.receivedReqs[i]
+		assert.Equal(t, method, req.Method)
+		assert.Equal(t, printSilentVal, req.URL.Query().Get(printParam))
+		assert.Equal(t, authToken, req.URL.Query().Get(authParam))
+	}
+}
+
+func TestRetry(t *testing.T) {
+	t.Parallel()
+	var (
+		reqs   int
+		bodies []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		reqs++
+		b, _ := ioutil.ReadAll(req.Body)
+		bodies = append(bodies, string(b))
+		if reqs < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(b)
+	}))
+	defer server.Close()
+
+	var backoffs []int
+	fb := New(server.URL, nil)
+	fb.SetRetry(5, func(attempt int) time.Duration {
+		backoffs = append(backoffs, attempt)
+		return time.Millisecond
+	})
+
+	require.NoError(t, fb.Set("foo"))
+	assert.Equal(t, 3, reqs)
+	assert.Equal(t, []int{1, 2}, backoffs)
+	// the body is sent in full on every attempt
+	assert.Equal(t, []string{`"foo"`, `"foo"`, `"foo"`}, bodies)
+}
+
+func TestIsTransient(t *testing.T) {
+	t.Parallel()
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	for err, transient := range map[error]bool{
+		ErrTimeout{errHeaderTimeout}:                              true,
+		&_url.Error{Op: "Get", URL: URL, Err: refused}:            true,
+		&_url.Error{Op: "Get", URL: URL, Err: io.EOF}:             true,
+		&_url.Error{Op: "Get", URL: URL, Err: syscall.ECONNRESET}: true,
+		refused: true,
+		&_url.Error{Op: "Get", URL: URL, Err: x509.UnknownAuthorityError{}}:                    false,
+		&_url.Error{Op: "Get", URL: URL, Err: errors.New("stopped after 30 redirects")}:        false,
+		&_url.Error{Op: "Get", URL: URL, Err: errors.New(`unsupported protocol scheme "ftp"`)}: false,
+		&FirebaseError{StatusCode: http.StatusBadGateway}:                                      true,
+		&FirebaseError{StatusCode: http.StatusBadRequest}:                                      false,
+		errors.New("other"): false,
+	} {
+		assert.Equal(t, transient, isTransient(err), "%v", err)
+	}
+}
+
+func TestRetryNonTransient(t *testing.T) {
+	t.Parallel()
+	var reqs int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		reqs++
+		if req.Method == "GET" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	fb.SetRetry(3, nil)
+
+	// 4xx responses are never retried
+	assert.Error(t, fb.Value(new(interface{})))
+	assert.Equal(t, 1, reqs)
+
+	// non-idempotent requests are never retried
+	assert.Error(t, fb.Update(true))
+	assert.Equal(t, 2, reqs)
+	_, err := fb.Push(true)
+	assert.Error(t, err)
+	assert.Equal(t, 3, reqs)
+
+	assert.Error(t, fb.Remove())
+	assert.Equal(t, 6, reqs)
+}
+
+func TestPushKey(t *testing.T) {
+	t.Parallel()
+	server := firetest.New()
+	server.Start()
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	key, childRef, err := fb.PushKey("foo")
+	require.NoError(t, err)
+	assert.NotEmpty(t, key)
+	assert.Equal(t, fb.url+"/"+key, childRef.url)
+	assert.Equal(t, "foo", server.Get(key))
+}
+
+func TestPushAndGet(t *testing.T) {
+	t.Parallel()
+	server := firetest.New()
+	server.Start()
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	var v map[string]string
+	childRef, err := fb.Child("list").PushAndGet(map[string]string{"foo": "bar"}, &v)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"foo": "bar"}, v)
+	assert.Equal(t, map[string]interface{}{"foo": "bar"}, server.Get(strings.TrimPrefix(childRef.url, server.URL+"/")))
+
+	_, err = fb.Child("list").PushAndGet(make(chan int), &v)
+	assert.Error(t, err)
+}
+
+func TestPing(t *testing.T) {
+	t.Parallel()
+	var (
+		server = newTestServer(`{"a":true}`)
+		fb     = New(server.URL, nil)
+	)
+	defer server.Close()
+
+	require.NoError(t, fb.OrderByKey().LimitToFirst(1).Ping(context.Background()))
+	require.Len(t, server.receivedReqs, 1)
+	assert.Equal(t, shallowParam+"=true", server.receivedReqs[0].URL.RawQuery)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.True(t, errors.Is(fb.Ping(ctx), context.Canceled))
+
+	denied := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error":"Permission denied"}`)
+	}))
+	defer denied.Close()
+	assert.True(t, IsUnauthorized(New(denied.URL, nil).Ping(context.Background())))
+}
+
+func TestSetDecoderOption(t *testing.T) {
+	t.Parallel()
+	var (
+		server = newTestServer(`{"id":9007199254740993}`)
+		fb     = New(server.URL, nil)
+	)
+	defer server.Close()
+
+	var v map[string]interface{}
+	require.NoError(t, fb.Value(&v))
+	assert.IsType(t, float64(0), v["id"])
+
+	fb.SetDecoderOption((*json.Decoder).UseNumber)
+	require.NoError(t, fb.Child("child").Value(&v))
+	assert.Equal(t, json.Number("9007199254740993"), v["id"])
+}
+
+func TestSetMarshalFunc(t *testing.T) {
+	t.Parallel()
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ = ioutil.ReadAll(req.Body)
+		fmt.Fprint(w, `{"name":"bob"}`)
+	}))
+	defer server.Close()
+
+	var (
+		fb       = New(server.URL, nil)
+		encoded  []interface{}
+		decoded  int
+		badValue = map[string]int{"a.b": 1}
+	)
+	fb.SetMarshalFunc(func(v interface{}) ([]byte, error) {
+		encoded = append(encoded, v)
+		return []byte(`{"custom":true}`), nil
+	})
+	fb.SetUnmarshalFunc(func(data []byte, v interface{}) error {
+		decoded++
+		return json.Unmarshal(data, v)
+	})
+
+	child := fb.Child("child")
+	require.NoError(t, child.Set("foo"))
+	assert.Equal(t, []interface{}{"foo"}, encoded)
+	assert.Equal(t, `{"custom":true}`, string(body))
+
+	var v map[string]string
+	require.NoError(t, child.Value(&v))
+	assert.Equal(t, map[string]string{"name": "bob"}, v)
+	assert.Equal(t, 1, decoded)
+
+	// the encoded keys are still validated
+	fb.SetMarshalFunc(json.Marshal)
+	assert.Error(t, fb.Set(badValue))
+}
+
+func TestSetMaxWriteSize(t *testing.T) {
+	t.Parallel()
+	var (
+		server = newTestServer(`null`)
+		fb     = New(server.URL, nil)
+	)
+	defer server.Close()
+
+	fb.SetMaxWriteSize(10)
+	require.NoError(t, fb.Set("short"))
+	err := fb.Set("much too long")
+	assert.True(t, errors.Is(err, ErrPayloadTooLarge))
+	assert.EqualError(t, err, "payload too large: 15 bytes exceeds the limit of 10 bytes")
+	_, err = fb.Push(map[string]string{"foo": "bar"})
+	assert.True(t, errors.Is(err, ErrPayloadTooLarge))
+	assert.Len(t, server.receivedReqs, 1)
+
+	fb.SetMaxWriteSize(0)
+	assert.NoError(t, fb.Set("much too long"))
+}
+
+func TestSetMaxResponseBytes(t *testing.T) {
+	t.Parallel()
+	var (
+		server = newTestServer(`"0123456789"`)
+		fb     = New(server.URL, nil)
+		v      string
+	)
+	defer server.Close()
+
+	fb.SetMaxResponseBytes(12)
+	require.NoError(t, fb.Value(&v))
+	assert.Equal(t, "0123456789", v)
+
+	fb.SetMaxResponseBytes(11)
+	err := fb.Child("child").Value(&v)
+	assert.True(t, errors.Is(err, ErrResponseTooLarge))
+	assert.EqualError(t, err, "response too large: more than 11 bytes")
+
+	// retrying would not help
+	fb.SetRetry(3, nil)
+	assert.Error(t, fb.Value(&v))
+	assert.Len(t, server.receivedReqs, 3)
+}
+
+func TestUpdateChildren(t *testing.T) {
+	t.Parallel()
+	var (
+		method string
+		body   []byte
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		method = req.Method
+		body, _ = ioutil.ReadAll(req.Body)
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	err := fb.UpdateChildren(map[string]interface{}{
+		"users/u1/name": "alice",
+		"users/u2/name": "bob",
+		"count":         2,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "PATCH", method)
+	assert.JSONEq(t, `{"users/u1/name":"alice","users/u2/name":"bob","count":2}`, string(body))
+}
+
+func TestUpdateChildrenInvalidPath(t *testing.T) {
+	t.Parallel()
+	var (
+		server = newTestServer("")
+		fb     = New(server.URL, nil)
+	)
+	defer server.Close()
+
+	for _, path := range []string{
+		"",
+		"/users/u1",
+		"users//u1",
+		"users/u1/",
+		"users/u.1",
+	} {
+		err := fb.UpdateChildren(map[string]interface{}{
+			"valid/path": true,
+			path:         true,
+		})
+		assert.Error(t, err, "path: %q", path)
+	}
+	assert.Len(t, server.receivedReqs, 0)
+}
+
+func TestTokenSource(t *testing.T) {
+	t.Parallel()
+	var (
+		server = newTestServer("")
+		fb     = New(server.URL, nil)
+		calls  int
+	)
+	defer server.Close()
+
+	fb.SetTokenSource(TokenSourceFunc(func() (string, error) {
+		calls++
+		return fmt.Sprintf("token-%d", calls), nil
+	}))
+	fb.Value("")
+	fb.Child("foo").Value("")
+	require.Len(t, server.receivedReqs, 2)
+	assert.Equal(t, "token-1", server.receivedReqs[0].URL.Query().Get(accessTokenParam))
+	assert.Equal(t, "token-2", server.receivedReqs[1].URL.Query().Get(accessTokenParam))
+
+	tokenErr := errors.New("no token")
+	fb.SetTokenSource(TokenSourceFunc(func() (string, error) {
+		return "", tokenErr
+	}))
+	assert.Error(t, fb.Value(""))
+	assert.Len(t, server.receivedReqs, 2)
+
+	fb.SetTokenSource(nil)
+	fb.Value("")
+	require.Len(t, server.receivedReqs, 3)
+	assert.Empty(t, server.receivedReqs[2].URL.Query().Get(accessTokenParam))
+}
+
+func TestOAuth2TokenSource(t *testing.T) {
+	t.Parallel()
+	ts := OAuth2TokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "access"}))
+	token, err := ts.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "access", token)
+}
+
+func TestSetAuthOverride(t *testing.T) {
+	t.Parallel()
+	var (
+		server = newTestServer(`null`)
+		fb     = New(server.URL, nil)
+		v      interface{}
+	)
+	defer server.Close()
+
+	require.NoError(t, fb.SetAuthOverride(map[string]interface{}{"uid": "alice", "admin": false}))
+	require.NoError(t, fb.Child("child").Value(&v))
+	require.NoError(t, fb.SetAuthOverride(nil))
+	require.NoError(t, fb.Value(&v))
+	fb.ClearAuthOverride()
+	require.NoError(t, fb.Value(&v))
+	require.Len(t, server.receivedReqs, 3)
+
+	assert.JSONEq(t, `{"uid":"alice","admin":false}`, server.receivedReqs[0].URL.Query().Get(authOverrideParam))
+	assert.Equal(t, `null`, server.receivedReqs[1].URL.Query().Get(authOverrideParam))
+	assert.Empty(t, server.receivedReqs[2].URL.RawQuery)
+
+	assert.Error(t, fb.SetAuthOverride(map[string]interface{}{"uid": make(chan int)}))
+	assert.Empty(t, fb.Params())
+
+	// the override is not a filter
+	require.NoError(t, fb.SetAuthOverride(nil))
+	fb.ClearParams()
+	assert.Equal(t, `null`, fb.Params().Get(authOverrideParam))
+}
+
+func TestSetAuthMode(t *testing.T) {
+	t.Parallel()
+	var (
+		server = newTestServer("")
+		fb     = New(server.URL, nil)
+	)
+	defer server.Close()
+
+	fb.Auth(authToken)
+	fb.SetAuthMode(AuthHeader)
+	fb.OrderBy("foo").Value("")
+	require.Len(t, server.receivedReqs, 1)
+
+	req := server.receivedReqs[0]
+	assert.Equal(t, "Bearer "+authToken, req.Header.Get("Authorization"))
+	assert.Equal(t, orderByParam+"=%22foo%22", req.URL.Query().Encode())
+
+	// access tokens win over legacy tokens
+	fb.SetTokenSource(TokenSourceFunc(func() (string, error) {
+		return "access", nil
+	}))
+	fb.Value("")
+	require.Len(t, server.receivedReqs, 2)
+	req = server.receivedReqs[1]
+	assert.Equal(t, "Bearer access", req.Header.Get("Authorization"))
+	assert.Equal(t, "", req.URL.Query().Encode())
+
+	fb.SetAuthMode(AuthQuery)
+	fb.Value("")
+	require.Len(t, server.receivedReqs, 3)
+	req = server.receivedReqs[2]
+	assert.Empty(t, req.Header.Get("Authorization"))
+	assert.Equal(t, authToken, req.URL.Query().Get(authParam))
+	assert.Equal(t, "access", req.URL.Query().Get(accessTokenParam))
+}
+
+func TestSetHeader(t *testing.T) {
+	t.Parallel()
+	var (
+		server = newTestServer(`null`)
+		fb     = New(server.URL, nil)
+	)
+	defer server.Close()
+
+	fb.SetHeader("X-Client-Version", "1.0")
+	fb.SetHeader("X-Api-Key", "key")
+	child := fb.Child("child")
+	child.DelHeader("X-Api-Key")
+
+	var v interface{}
+	require.NoError(t, fb.Value(&v))
+	require.NoError(t, child.Value(&v))
+	require.Len(t, server.receivedReqs, 2)
+	assert.Equal(t, "1.0", server.receivedReqs[0].Header.Get("X-Client-Version"))
+	assert.Equal(t, "key", server.receivedReqs[0].Header.Get("X-Api-Key"))
+	assert.Equal(t, "1.0", server.receivedReqs[1].Header.Get("X-Client-Version"))
+	assert.Empty(t, server.receivedReqs[1].Header.Get("X-Api-Key"))
+}
+
+func TestSetUserAgent(t *testing.T) {
+	t.Parallel()
+	var (
+		server = newTestServer(`null`)
+		fb     = New(server.URL, nil)
+		v      interface{}
+	)
+	defer server.Close()
+
+	require.NoError(t, fb.Value(&v))
+	fb.SetUserAgent("my-app/1.0")
+	require.NoError(t, fb.Child("child").Value(&v))
+	fb.SetUserAgent("")
+	require.NoError(t, fb.Value(&v))
+	require.Len(t, server.receivedReqs, 3)
+
+	assert.Equal(t, "firego/devel", server.receivedReqs[0].Header.Get("User-Agent"))
+	assert.Equal(t, "my-app/1.0", server.receivedReqs[1].Header.Get("User-Agent"))
+	assert.Equal(t, "Go-http-client/1.1", server.receivedReqs[2].Header.Get("User-Agent"))
+}
+
+func TestSetHeaderRedirect(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("redirected") == "" {
+			http.Redirect(w, req, req.URL.Path+"?redirected=1", http.StatusTemporaryRedirect)
+			return
+		}
+		assert.Equal(t, "1.0", req.Header.Get("X-Client-Version"))
+		fmt.Fprint(w, `null`)
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	fb.SetHeader("X-Client-Version", "1.0")
+	var v interface{}
+	assert.NoError(t, fb.Value(&v))
+}
+
+func TestRedirectAuthorization(t *testing.T) {
+	t.Parallel()
+	authorization := make(chan string, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		authorization <- req.Header.Get("Authorization")
+		assert.Empty(t, req.Header.Get("X-Client-Version"))
+		assert.Equal(t, "agent", req.Header.Get("User-Agent"))
+		fmt.Fprint(w, `null`)
+	}))
+	defer target.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("redirected") == "" {
+			http.Redirect(w, req, req.URL.Path+"?redirected=1", http.StatusTemporaryRedirect)
+			return
+		}
+		http.Redirect(w, req, target.URL, http.StatusTemporaryRedirect)
+	}))
+	defer server.Close()
+
+	var v interface{}
+	fb := New(server.URL, nil)
+	fb.Auth(authToken)
+	fb.SetAuthMode(AuthHeader)
+	fb.SetHeader("X-Client-Version", "1.0")
+	fb.SetUserAgent("agent")
+
+	// the first redirect stays on the same host, the second one does not
+	require.NoError(t, fb.Value(&v))
+	assert.Empty(t, <-authorization)
+
+	// redirects within the same host keep the credentials
+	same := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("redirected") == "" {
+			http.Redirect(w, req, req.URL.Path+"?redirected=1", http.StatusTemporaryRedirect)
+			return
+		}
+		authorization <- req.Header.Get("Authorization")
+		assert.Equal(t, "1.0", req.Header.Get("X-Client-Version"))
+		fmt.Fprint(w, `null`)
+	}))
+	defer same.Close()
+
+	fb.SetURL(same.URL)
+	require.NoError(t, fb.Value(&v))
+	assert.Equal(t, "Bearer "+authToken, <-authorization)
+}
+
+func TestSetRedirectLimit(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n, _ := strconv.Atoi(req.URL.Query().Get("n"))
+		if n < 3 {
+			http.Redirect(w, req, req.URL.Path+"?n="+strconv.Itoa(n+1), http.StatusTemporaryRedirect)
+			return
+		}
+		fmt.Fprint(w, `null`)
+	}))
+	defer server.Close()
+
+	var v interface{}
+	fb := New(server.URL, nil)
+	assert.NoError(t, fb.Value(&v))
+
+	limited := fb.Child("limited")
+	limited.SetRedirectLimit(2)
+	err := limited.Value(&v)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "3 consecutive requests(redirects)")
+
+	// the limit of a reference does not apply to the others sharing its client
+	assert.NoError(t, fb.Value(&v))
+}
+
+func TestTrustedRedirect(t *testing.T) {
+	t.Parallel()
+	for _, test := range []struct {
+		from, to string
+		trusted  bool
+	}{
+		{"https://db.firebaseio.com/a", "https://db.firebaseio.com/b", true},
+		{"https://db.firebaseio.com", "https://db-2.firebaseio.com", true},
+		{"https://db.firebaseio.com", "https://db.europe-west1.firebasedatabase.app", true},
+		{"https://db.firebaseio.com", "https://example.com", false},
+		{"https://db.firebaseio.com", "https://firebaseio.com.example.com", false},
+		{"http://localhost:9000", "http://localhost:9001", false},
+		{"https://db.firebaseio.com", "http://db.firebaseio.com", false},
+		{"https://db.firebaseio.com", "http://db-2.firebaseio.com", false},
+	} {
+		from, err := _url.Parse(test.from)
+		require.NoError(t, err)
+		to, err := _url.Parse(test.to)
+		require.NoError(t, err)
+		assert.Equal(t, test.trusted, trustedRedirect(from, to), "%s -> %s", test.from, test.to)
+	}
+}
+
+func TestGetWithHeader(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Custom", "foo")
+		if req.URL.Path == "/missing/.json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `"bar"`)
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	var v string
+	headers, err := fb.GetWithHeader(&v)
+	require.NoError(t, err)
+	assert.Equal(t, "bar", v)
+	assert.Equal(t, "foo", headers.Get("X-Custom"))
+	assert.Equal(t, "5", headers.Get("Content-Length"))
+
+	// headers are available on errors as well
+	headers, err = fb.Child("missing").GetWithHeader(&v)
+	assert.True(t, IsNotFound(err))
+	assert.Equal(t, "foo", headers.Get("X-Custom"))
+}
+
+func TestExportValue(t *testing.T) {
+	t.Parallel()
+	const exported = `{".priority":1,"foo":{".priority":"a",".value":"bar"}}`
+	var received []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "GET" {
+			assert.Equal(t, formatVal, req.URL.Query().Get(formatParam))
+			fmt.Fprint(w, exported)
+			return
+		}
+		received, _ = ioutil.ReadAll(req.Body)
+		w.Write(received)
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	var v map[string]interface{}
+	require.NoError(t, fb.ExportValue(&v))
+	assert.Equal(t, map[string]interface{}{
+		".priority": float64(1),
+		"foo": map[string]interface{}{
+			".priority": "a",
+			".value":    "bar",
+		},
+	}, v)
+
+	// the exported value can be written back as is
+	require.NoError(t, fb.Set(v))
+	assert.JSONEq(t, exported, string(received))
+}
+
+func TestSetWithPriority(t *testing.T) {
+	t.Parallel()
+	var received []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		received, _ = ioutil.ReadAll(req.Body)
+		w.Write(received)
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	require.NoError(t, fb.SetWithPriority(map[string]string{"name": "foo"}, 3))
+	assert.JSONEq(t, `{".value":{"name":"foo"},".priority":3}`, string(received))
+}
+
+func TestDoRaw(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "text/plain", req.Header.Get("Accept"))
+		body, _ := ioutil.ReadAll(req.Body)
+		w.Header().Set("X-Method", req.Method)
+		w.WriteHeader(http.StatusTeapot)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	resp, err := fb.DoRaw("PUT", []byte(`"foo"`), withHeader("Accept", "text/plain"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusTeapot, resp.StatusCode)
+	assert.Equal(t, "PUT", resp.Header.Get("X-Method"))
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, `"foo"`, string(body))
 }
@@ -0,0 +1,86 @@
+package firego
+
+import (
+	"net/http"
+	_url "net/url"
+	"time"
+)
+
+// RequestInfo describes a request made to Firebase once it completed.
+type RequestInfo struct {
+	// Method is the HTTP method of the request.
+	Method string
+	// URL is the URL of the request, without any credentials.
+	URL string
+	// Duration is the time it took to receive the response headers.
+	Duration time.Duration
+	// StatusCode is the status code of the response, or 0 if no
+	// response was received.
+	StatusCode int
+	// Err is the error that prevented a response from being received.
+	Err error
+}
+
+// OnRequest registers a function that is called with every request right
+// before it is sent, retries included. It is meant for instrumentation such
+// as propagating tracing headers, it should not otherwise modify the request.
+//
+// Hooks are inherited by references derived from this one.
+func (fb *Firebase) OnRequest(hook func(req *http.Request)) {
+	fb.paramsMtx.Lock()
+	fb.requestHooks = append(fb.requestHooks[:len(fb.requestHooks):len(fb.requestHooks)], hook)
+	fb.paramsMtx.Unlock()
+}
+
+// OnResponse registers a function that is called once every request
+// completed, successfully or not, retries included.
+//
+// Hooks are inherited by references derived from this one.
+func (fb *Firebase) OnResponse(hook func(info RequestInfo)) {
+	fb.paramsMtx.Lock()
+	fb.responseHooks = append(fb.responseHooks[:len(fb.responseHooks):len(fb.responseHooks)], hook)
+	fb.paramsMtx.Unlock()
+}
+
+// OnTiming registers a function that is called once every read or write,
+// such as Value or Set, completed, successfully or not, with its method and
+// how long it took, from sending the request to reading the whole response,
+// retries included. Unlike OnResponse, it is called once per operation.
+//
+// Hooks are inherited by references derived from this one.
+func (fb *Firebase) OnTiming(hook func(method string, d time.Duration)) {
+	fb.paramsMtx.Lock()
+	fb.timingHooks = append(fb.timingHooks[:len(fb.timingHooks):len(fb.timingHooks)], hook)
+	fb.paramsMtx.Unlock()
+}
+
+// reportTiming calls the timing hooks with the duration
+// of the operation with the given method started at start.
+func (fb *Firebase) reportTiming(method string, start time.Time) {
+	fb.paramsMtx.RLock()
+	hooks := fb.timingHooks
+	fb.paramsMtx.RUnlock()
+	if len(hooks) == 0 {
+		return
+	}
+	d := time.Since(start)
+	for _, hook := range hooks {
+		hook(method, d)
+	}
+}
+
+func (fb *Firebase) getHooks() ([]func(*http.Request), []func(RequestInfo)) {
+	fb.paramsMtx.RLock()
+	defer fb.paramsMtx.RUnlock()
+	return fb.requestHooks, fb.responseHooks
+}
+
+// redactURL returns the given URL without the credentials it may hold.
+func redactURL(u *_url.URL) string {
+	redacted := *u
+	query := redacted.Query()
+	query.Del(authParam)
+	query.Del(accessTokenParam)
+	redacted.RawQuery = query.Encode()
+	return redacted.String()
+}
@@ -0,0 +1,99 @@
+package firego
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trevor403/firego/firetest"
+)
+
+func TestHooks(t *testing.T) {
+	t.Parallel()
+	server := firetest.New()
+	server.Start()
+	defer server.Close()
+
+	var (
+		requests []string
+		infos    []RequestInfo
+	)
+	fb := New(server.URL, nil)
+	fb.Auth("secret")
+	fb.OnRequest(func(req *http.Request) {
+		requests = append(requests, req.Method)
+	})
+	fb.OnResponse(func(info RequestInfo) {
+		infos = append(infos, info)
+	})
+
+	child := fb.Child("foo")
+	require.NoError(t, child.Set("bar"))
+	var v string
+	require.NoError(t, child.Value(&v))
+
+	assert.Equal(t, []string{"PUT", "GET"}, requests)
+	require.Len(t, infos, 2)
+	for i, info := range infos {
+		assert.Equal(t, requests[i], info.Method)
+		assert.Equal(t, http.StatusOK, info.StatusCode)
+		assert.NoError(t, info.Err)
+		assert.True(t, strings.HasPrefix(info.URL, server.URL+"/foo/.json"), info.URL)
+		assert.NotContains(t, info.URL, "secret")
+	}
+}
+
+func TestHooks_Error(t *testing.T) {
+	t.Parallel()
+	var info RequestInfo
+	fb := New("http://127.0.0.1:1", nil)
+	fb.OnResponse(func(i RequestInfo) {
+		info = i
+	})
+
+	err := fb.Set("bar")
+	assert.Error(t, err)
+	assert.Equal(t, "PUT", info.Method)
+	assert.Equal(t, 0, info.StatusCode)
+	assert.Error(t, info.Err)
+}
+
+func TestOnTiming(t *testing.T) {
+	t.Parallel()
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		attempts++
+		time.Sleep(10 * time.Millisecond)
+		if req.Method == "DELETE" || attempts == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("null"))
+	}))
+	defer server.Close()
+
+	type timing struct {
+		method string
+		d      time.Duration
+	}
+	var timings []timing
+	fb := New(server.URL, nil)
+	fb.SetRetry(2, nil)
+	fb.OnTiming(func(method string, d time.Duration) {
+		timings = append(timings, timing{method, d})
+	})
+
+	require.NoError(t, fb.Child("a").Value(new(interface{})))
+	fb.SetRetry(1, nil)
+	assert.Error(t, fb.Remove())
+
+	require.Len(t, timings, 2)
+	assert.Equal(t, "GET", timings[0].method)
+	assert.True(t, timings[0].d >= 20*time.Millisecond, "retries are included: %s", timings[0].d)
+	assert.Equal(t, "DELETE", timings[1].method)
+	assert.True(t, timings[1].d >= 10*time.Millisecond, "%s", timings[1].d)
+}
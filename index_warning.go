@@ -0,0 +1,35 @@
+package firego
+
+import "regexp"
+
+// indexNotDefined matches the error message of Firebase
+// for queries ordered by a child that is not indexed.
+var indexNotDefined = regexp.MustCompile(`Index not defined, add "\.indexOn": "([^"]*)", for path "([^"]*)", to the rules`)
+
+// SetIndexWarningHandler sets a function that is called when a query is
+// rejected by Firebase because it is ordered by a child that is not indexed,
+// with the path of the queried location and the child to add to its
+// ".indexOn" rule. The query still fails with a FirebaseError, the handler
+// helps noticing missing indexes during development, by logging them for
+// instance. A nil handler removes it.
+//
+// Reference https://firebase.google.com/docs/database/security/indexing-data
+func (fb *Firebase) SetIndexWarningHandler(handler func(path, field string)) {
+	fb.paramsMtx.Lock()
+	fb.indexWarningHandler = handler
+	fb.paramsMtx.Unlock()
+}
+
+// checkIndexWarning calls the index warning handler if
+// the given error is caused by a missing index.
+func (fb *Firebase) checkIndexWarning(err *FirebaseError) {
+	fb.paramsMtx.RLock()
+	handler := fb.indexWarningHandler
+	fb.paramsMtx.RUnlock()
+	if handler == nil {
+		return
+	}
+	if match := indexNotDefined.FindStringSubmatch(err.Error()); match != nil {
+		handler(match[2], match[1])
+	}
+}
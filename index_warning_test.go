@@ -0,0 +1,42 @@
+package firego
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetIndexWarningHandler(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get(orderByParam) == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"Invalid data"}`))
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"Index not defined, add \".indexOn\": \"height\", for path \"/dinosaurs\", to the rules"}`))
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil).Child("dinosaurs")
+	var warnings [][2]string
+	fb.SetIndexWarningHandler(func(path, field string) {
+		warnings = append(warnings, [2]string{path, field})
+	})
+
+	var v interface{}
+	err := fb.OrderBy("height").Value(&v)
+	require.Error(t, err)
+	assert.Equal(t, [][2]string{{"/dinosaurs", "height"}}, warnings)
+
+	assert.Error(t, fb.Value(&v))
+	assert.Len(t, warnings, 1)
+
+	fb.SetIndexWarningHandler(nil)
+	assert.Error(t, fb.OrderBy("height").Value(&v))
+	assert.Len(t, warnings, 1)
+}
@@ -0,0 +1,41 @@
+package firego
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+const infoPath = ".info"
+
+// Info returns a reference to the given child of the .info namespace of the
+// database, which holds metadata such as "connected" or "serverTimeOffset",
+// regardless of the path of the reference. An empty child refers to the
+// namespace itself.
+//
+// Reference https://firebase.google.com/docs/database/web/offline-capabilities
+func (fb *Firebase) Info(child string) *Firebase {
+	ref := fb.specialRef(infoPath)
+	if child = strings.Trim(child, "/"); child != "" {
+		ref = ref.Child(child)
+	}
+	return ref
+}
+
+// ServerTimeOffset returns the estimated difference between the clock of
+// the Firebase servers and the local clock, regardless of the path of the
+// reference. Adding it to the local time gives an estimate of the server time.
+//
+// Reference https://firebase.google.com/docs/database/web/offline-capabilities#clock-skew
+func (fb *Firebase) ServerTimeOffset() (time.Duration, error) {
+	_, body, err := fb.Info("serverTimeOffset").doRequest(context.Background(), "GET", nil)
+	if err != nil {
+		return 0, err
+	}
+	var offset float64
+	if err := json.Unmarshal(body, &offset); err != nil {
+		return 0, err
+	}
+	return time.Duration(offset * float64(time.Millisecond)), nil
+}
@@ -0,0 +1,45 @@
+package firego
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerTimeOffset(t *testing.T) {
+	t.Parallel()
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		path = req.URL.Path
+		w.Write([]byte(`-1500.5`))
+	}))
+	defer server.Close()
+
+	fb := New(server.URL+"/some/path", nil)
+	offset, err := fb.ServerTimeOffset()
+	require.NoError(t, err)
+	assert.Equal(t, "/.info/serverTimeOffset/.json", path)
+	assert.Equal(t, -1500500*time.Microsecond, offset)
+}
+
+func TestInfo(t *testing.T) {
+	t.Parallel()
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		path = req.URL.Path
+		w.Write([]byte(`true`))
+	}))
+	defer server.Close()
+
+	fb := New(server.URL+"/some/path", nil)
+	var connected bool
+	require.NoError(t, fb.Info("connected").Value(&connected))
+	assert.Equal(t, "/.info/connected/.json", path)
+	assert.True(t, connected)
+
+	assert.Equal(t, server.URL+"/.info", fb.Info("").URL())
+}
@@ -0,0 +1,159 @@
+package firego
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+)
+
+const defaultPageSize = 100
+
+// PageSize creates a new Firebase reference whose Iterator
+// reads the given number of children per page.
+func (fb *Firebase) PageSize(n int) *Firebase {
+	c := fb.copy()
+	c.pageSize = n
+	return c
+}
+
+// Iterator pages through the children of a Firebase reference
+// ordered by key.
+type Iterator struct {
+	ref      *Firebase
+	pageSize int
+	lastKey  string
+	started  bool
+}
+
+// Iterator creates an Iterator that pages through the children of the
+// reference, ordered by key, reading PageSize children at a time.
+// Only key ordering is supported, the limit and range configuration of
+// the reference are replaced by the iterator.
+//
+//	it := fb.OrderByKey().PageSize(100).Iterator()
+//	for {
+//		var page map[string]User
+//		more, err := it.Next(&page)
+//		if err != nil {
+//			log.Fatal(err)
+//		}
+//		// use page
+//		if !more {
+//			break
+//		}
+//	}
+func (fb *Firebase) Iterator() *Iterator {
+	pageSize := fb.pageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	return &Iterator{
+		ref:      fb,
+		pageSize: pageSize,
+	}
+}
+
+// Next reads the next page of children into v, which should be able to
+// hold a JSON object such as a map. The returned bool reports whether more
+// children may follow, once it is false the iteration is over.
+func (it *Iterator) Next(v interface{}) (bool, error) {
+	it.ref.paramsMtx.RLock()
+	orderBy := it.ref.params.Get(orderByParam)
+	it.ref.paramsMtx.RUnlock()
+	if orderBy != "" && orderBy != escapeParameter(orderByKey) {
+		return false, errors.New("iterator only supports ordering by key")
+	}
+
+	ref := it.ref.OrderByKey()
+	// explicitly not locking here because no one else
+	// has access to this reference.
+	ref.params.Del(startAtParam)
+	ref.params.Del(equalToParam)
+	ref.params.Del(limitToLastParam)
+
+	limit := it.pageSize
+	if it.started {
+		// startAt is inclusive, fetch one more child to make up for the
+		// last child of the previous page
+		ref.params.Set(startAtParam, escapeParameter(it.lastKey))
+		limit++
+	}
+	ref.params.Set(limitToFirstParam, strconv.Itoa(limit))
+
+	_, body, err := ref.doRequest(context.Background(), "GET", nil)
+	if err != nil {
+		return false, err
+	}
+
+	var children map[string]json.RawMessage
+	if err := json.Unmarshal(body, &children); err != nil {
+		return false, err
+	}
+	more := len(children) == limit
+
+	if it.started {
+		delete(children, it.lastKey)
+	}
+	if len(children) == 0 {
+		more = false
+	}
+
+	keys := make([]string, 0, len(children))
+	for k := range children {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return compareKeys(keys[i], keys[j]) < 0
+	})
+	if len(keys) > 0 {
+		it.lastKey = keys[len(keys)-1]
+	}
+	it.started = true
+
+	page, err := json.Marshal(children)
+	if err != nil {
+		return false, err
+	}
+	return more, it.ref.unmarshal(page, v)
+}
+
+// compareKeys orders keys the way Firebase does: keys that can be parsed as
+// 32-bit integers come first in numeric order, followed by the other keys
+// in lexicographical order.
+func compareKeys(a, b string) int {
+	ai, aErr := parseIntKey(a)
+	bi, bErr := parseIntKey(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		switch {
+		case ai < bi:
+			return -1
+		case ai > bi:
+			return 1
+		}
+		return 0
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func parseIntKey(key string) (int64, error) {
+	i, err := strconv.ParseInt(key, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	if strconv.FormatInt(i, 10) != key {
+		// leading zeros or a plus sign make it a string key
+		return 0, strconv.ErrSyntax
+	}
+	return i, nil
+}
@@ -0,0 +1,137 @@
+package firego
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPagingServer serves the given children honoring the
+// orderBy="$key", startAt and limitToFirst parameters.
+func newPagingServer(t *testing.T, children map[string]int) *httptest.Server {
+	keys := make([]string, 0, len(children))
+	for k := range children {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return compareKeys(keys[i], keys[j]) < 0
+	})
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		assert.Equal(t, `"$key"`, query.Get(orderByParam))
+
+		var startAt string
+		if s := query.Get(startAtParam); s != "" {
+			require.NoError(t, json.Unmarshal([]byte(s), &startAt))
+		}
+		limit, err := strconv.Atoi(query.Get(limitToFirstParam))
+		require.NoError(t, err)
+
+		page := map[string]int{}
+		for _, k := range keys {
+			if len(page) == limit {
+				break
+			}
+			if startAt != "" && compareKeys(k, startAt) < 0 {
+				continue
+			}
+			page[k] = children[k]
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+}
+
+func TestIterator(t *testing.T) {
+	t.Parallel()
+	children := map[string]int{}
+	for i := 0; i < 10; i++ {
+		children[strconv.Itoa(i)] = i
+	}
+	for _, k := range []string{"a", "b", "c"} {
+		children[k] = len(children)
+	}
+	server := newPagingServer(t, children)
+	defer server.Close()
+
+	it := New(server.URL, nil).OrderByKey().PageSize(4).Iterator()
+
+	seen := map[string]int{}
+	var pages []int
+	for {
+		var page map[string]int
+		more, err := it.Next(&page)
+		require.NoError(t, err)
+		pages = append(pages, len(page))
+		for k, v := range page {
+			_, dup := seen[k]
+			assert.False(t, dup, "key %s returned twice", k)
+			seen[k] = v
+		}
+		if !more {
+			break
+		}
+	}
+	assert.Equal(t, children, seen)
+	assert.Equal(t, []int{4, 4, 4, 1}, pages)
+}
+
+func TestIteratorExactPages(t *testing.T) {
+	t.Parallel()
+	server := newPagingServer(t, map[string]int{"a": 1, "b": 2, "c": 3, "d": 4})
+	defer server.Close()
+
+	it := New(server.URL, nil).PageSize(2).Iterator()
+	var pages []map[string]int
+	for {
+		var page map[string]int
+		more, err := it.Next(&page)
+		require.NoError(t, err)
+		pages = append(pages, page)
+		if !more {
+			break
+		}
+	}
+	assert.Equal(t, []map[string]int{
+		{"a": 1, "b": 2},
+		{"c": 3, "d": 4},
+		{},
+	}, pages)
+}
+
+func TestIteratorEmpty(t *testing.T) {
+	t.Parallel()
+	server := newTestServer("null")
+	defer server.Close()
+
+	var page map[string]interface{}
+	more, err := New(server.URL, nil).Iterator().Next(&page)
+	require.NoError(t, err)
+	assert.False(t, more)
+	assert.Empty(t, page)
+}
+
+func TestIteratorOrderBy(t *testing.T) {
+	t.Parallel()
+	server := newTestServer("null")
+	defer server.Close()
+
+	_, err := New(server.URL, nil).OrderBy("name").Iterator().Next(new(interface{}))
+	assert.Error(t, err)
+	assert.Len(t, server.receivedReqs, 0)
+}
+
+func TestCompareKeys(t *testing.T) {
+	t.Parallel()
+	keys := []string{"b", "10", "a", "-1", "2", "01", "+3", "9999999999", "A"}
+	sort.Slice(keys, func(i, j int) bool {
+		return compareKeys(keys[i], keys[j]) < 0
+	})
+	assert.Equal(t, []string{"-1", "2", "10", "+3", "01", "9999999999", "A", "a", "b"}, keys)
+}
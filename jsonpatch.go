@@ -0,0 +1,96 @@
+package firego
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// jsonPatchOperation is an operation of a JSON Patch document.
+type jsonPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// ApplyJSONPatch applies the given JSON Patch (RFC 6902) document to the
+// current reference with a single multi-path update, so the operations are
+// applied atomically. The add and replace operations write their value at
+// their path and remove operations delete their path.
+//
+// Since an update is made without reading the data, only the operations
+// that do not depend on the current data are supported: move, copy and test
+// operations are rejected, as are paths appending to an array ("-"). Unlike
+// what RFC 6902 requires, replace and remove operations succeed whether or
+// not their path exists, and an add operation at an array index, such as
+// /list/1, replaces the element at that index instead of inserting its
+// value before it, Firebase storing arrays as objects with numeric keys.
+// Operations on the same path are combined, the last
+// one winning, but an error is returned if the path of an operation is below
+// the path of another, as Firebase rejects such updates, or if it targets the
+// whole document.
+//
+// Reference https://tools.ietf.org/html/rfc6902
+func (fb *Firebase) ApplyJSONPatch(patch []byte) error {
+	var operations []jsonPatchOperation
+	if err := json.Unmarshal(patch, &operations); err != nil {
+		return fmt.Errorf("invalid JSON patch: %s", err)
+	}
+
+	update := map[string]interface{}{}
+	for _, op := range operations {
+		path, err := pointerPath(op.Path)
+		if err != nil {
+			return err
+		}
+
+		switch op.Op {
+		case "add", "replace":
+			if op.Value == nil {
+				return fmt.Errorf("invalid JSON patch: %s operation on %q has no value", op.Op, op.Path)
+			}
+			update[path] = op.Value
+		case "remove":
+			update[path] = nil
+		default:
+			return fmt.Errorf("unsupported JSON patch operation %q", op.Op)
+		}
+	}
+
+	for path := range update {
+		for other := range update {
+			if path != other && isSubPath(path, other) {
+				return fmt.Errorf("JSON patch paths %q and %q overlap", path, other)
+			}
+		}
+	}
+	if len(update) == 0 {
+		return nil
+	}
+	return fb.UpdateChildren(update)
+}
+
+// pointerPath converts a JSON Pointer (RFC 6901) to a relative path.
+func pointerPath(pointer string) (string, error) {
+	if pointer == "" {
+		return "", errors.New("JSON patch operations on the whole document are not supported")
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return "", fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+
+	segments := strings.Split(pointer[1:], "/")
+	for i, segment := range segments {
+		if segment == "-" {
+			return "", fmt.Errorf("JSON pointer %q appends to an array, which is not supported", pointer)
+		}
+		segment = strings.ReplaceAll(segment, "~1", "/")
+		segment = strings.ReplaceAll(segment, "~0", "~")
+		if strings.Contains(segment, "/") {
+			return "", fmt.Errorf("invalid JSON pointer %q: keys cannot contain '/'", pointer)
+		}
+		segments[i] = segment
+	}
+	return strings.Join(segments, "/"), nil
+}
@@ -0,0 +1,90 @@
+package firego
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyJSONPatch(t *testing.T) {
+	t.Parallel()
+	var (
+		method string
+		body   []byte
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		method = req.Method
+		body, _ = ioutil.ReadAll(req.Body)
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	require.NoError(t, fb.ApplyJSONPatch([]byte(`[
+		{"op": "add", "path": "/users/alice", "value": {"name": "Alice"}},
+		{"op": "replace", "path": "/users/bob/age", "value": 42},
+		{"op": "remove", "path": "/users/carol"},
+		{"op": "add", "path": "/tilde~0key", "value": true},
+		{"op": "add", "path": "/list/0", "value": "first"},
+		{"op": "add", "path": "/users/dave", "value": 1},
+		{"op": "remove", "path": "/users/dave"}
+	]`)))
+	assert.Equal(t, "PATCH", method)
+	assert.JSONEq(t, `{
+		"users/alice": {"name": "Alice"},
+		"users/bob/age": 42,
+		"users/carol": null,
+		"tilde~key": true,
+		"list/0": "first",
+		"users/dave": null
+	}`, string(body))
+}
+
+func TestApplyJSONPatchArrayIndex(t *testing.T) {
+	t.Parallel()
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ = ioutil.ReadAll(req.Body)
+	}))
+	defer server.Close()
+
+	// the element at the index is replaced, the next ones are not shifted
+	fb := New(server.URL, nil)
+	require.NoError(t, fb.ApplyJSONPatch([]byte(`[{"op": "add", "path": "/list/1", "value": "x"}]`)))
+	assert.JSONEq(t, `{"list/1": "x"}`, string(body))
+}
+
+func TestApplyJSONPatchErrors(t *testing.T) {
+	t.Parallel()
+	server := newTestServer("")
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	for _, test := range []struct {
+		patch string
+		err   string
+	}{
+		{`{}`, "invalid JSON patch: json: cannot unmarshal object into Go value of type []firego.jsonPatchOperation"},
+		{`[{"op": "move", "from": "/a", "path": "/b"}]`, `unsupported JSON patch operation "move"`},
+		{`[{"op": "copy", "from": "/a", "path": "/b"}]`, `unsupported JSON patch operation "copy"`},
+		{`[{"op": "test", "path": "/a", "value": 1}]`, `unsupported JSON patch operation "test"`},
+		{`[{"op": "add", "path": "/a"}]`, `invalid JSON patch: add operation on "/a" has no value`},
+		{`[{"op": "add", "path": "", "value": 1}]`, "JSON patch operations on the whole document are not supported"},
+		{`[{"op": "add", "path": "a", "value": 1}]`, `invalid JSON pointer "a"`},
+		{`[{"op": "add", "path": "/list/-", "value": 1}]`, `JSON pointer "/list/-" appends to an array, which is not supported`},
+		{`[{"op": "add", "path": "/a~1b", "value": 1}]`, `invalid JSON pointer "/a~1b": keys cannot contain '/'`},
+		{`[{"op": "add", "path": "/a.b", "value": 1}]`, `invalid path "a.b": invalid key "a.b": key cannot contain '.'`},
+	} {
+		assert.EqualError(t, fb.ApplyJSONPatch([]byte(test.patch)), test.err, test.patch)
+	}
+
+	err := fb.ApplyJSONPatch([]byte(`[{"op": "add", "path": "/a", "value": {}}, {"op": "remove", "path": "/a/b"}]`))
+	assert.Contains(t, err.Error(), "overlap")
+	assert.Empty(t, server.receivedReqs)
+
+	assert.NoError(t, fb.ApplyJSONPatch([]byte(`[]`)))
+	assert.Empty(t, server.receivedReqs)
+}
@@ -0,0 +1,127 @@
+package firego
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxKeyLength is the maximum length of a key, in bytes.
+const maxKeyLength = 768
+
+// specialKeys are the keys Firebase gives a meaning to in written data.
+var specialKeys = map[string]bool{
+	".value":    true,
+	".priority": true,
+	".sv":       true,
+}
+
+// ValidateKey returns an error if the given string cannot be used as a key
+// by Firebase. Keys must be non-empty UTF-8 strings of at most 768 bytes and
+// cannot contain '.', '$', '#', '[', ']', '/' or ASCII control characters.
+//
+// Reference https://firebase.google.com/docs/database/web/structure-data#how_data_is_structured_its_a_json_tree
+func ValidateKey(key string) error {
+	switch {
+	case key == "":
+		return fmt.Errorf("invalid key %q: key is empty", key)
+	case len(key) > maxKeyLength:
+		return fmt.Errorf("invalid key %q: key is longer than %d bytes", key, maxKeyLength)
+	case !utf8.ValidString(key):
+		return fmt.Errorf("invalid key %q: key is not valid UTF-8", key)
+	}
+
+	for _, r := range key {
+		if strings.ContainsRune(".$#[]/", r) || r < 0x20 || r == 0x7f {
+			return fmt.Errorf("invalid key %q: key cannot contain %q", key, r)
+		}
+	}
+	return nil
+}
+
+// validatePath returns an error if any key of the given path is invalid.
+func validatePath(path string) error {
+	if path == "" {
+		return nil
+	}
+	for _, key := range strings.Split(path, "/") {
+		if err := ValidateKey(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// marshalValue marshals a value to write, making sure every key in it is valid.
+func (fb *Firebase) marshalValue(v interface{}) ([]byte, error) {
+	data, err := fb.marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return data, validateKeys(data, false)
+}
+
+// marshalUpdate marshals the value of an update, whose top level keys
+// may be paths relative to the updated location.
+func (fb *Firebase) marshalUpdate(v interface{}) ([]byte, error) {
+	data, err := fb.marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return data, validateKeys(data, true)
+}
+
+// validateKeys returns an error if any object key of the given
+// JSON document is invalid. When paths is set, the top level keys
+// are validated as relative paths.
+func validateKeys(data []byte, paths bool) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// the decoder does not tell keys from string values, keep
+	// track of the enclosing containers to know which is which
+	var (
+		stack []json.Delim
+		isKey []bool
+	)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		depth := len(stack)
+		if key, ok := tok.(string); ok && depth > 0 && stack[depth-1] == '{' && isKey[depth-1] {
+			switch {
+			case specialKeys[key]:
+			case paths && depth == 1:
+				err = validateRelativePath(key)
+			default:
+				err = ValidateKey(key)
+			}
+			if err != nil {
+				return err
+			}
+			isKey[depth-1] = false
+			continue
+		}
+
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			stack = append(stack, tok.(json.Delim))
+			isKey = append(isKey, tok == json.Delim('{'))
+			continue
+		case json.Delim('}'), json.Delim(']'):
+			stack, isKey = stack[:depth-1], isKey[:depth-1]
+		}
+
+		// a value was read, the next token of an object is a key
+		if depth := len(stack); depth > 0 && stack[depth-1] == '{' {
+			isKey[depth-1] = true
+		}
+	}
+}
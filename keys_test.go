@@ -0,0 +1,50 @@
+package firego
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateKey(t *testing.T) {
+	t.Parallel()
+	for _, key := range []string{"foo", "with space", "-KpushID", "ünïcode", "a?b", strings.Repeat("a", maxKeyLength)} {
+		assert.NoError(t, ValidateKey(key), "key: %q", key)
+	}
+	for _, key := range []string{"", "a.b", "$key", "a#b", "a[0]", "a]", "a/b", "a\nb", "a\x7fb", "\xff", strings.Repeat("a", maxKeyLength+1)} {
+		assert.Error(t, ValidateKey(key), "key: %q", key)
+	}
+}
+
+func TestInvalidKeys(t *testing.T) {
+	t.Parallel()
+	var (
+		server = newTestServer("")
+		fb     = New(server.URL, nil)
+	)
+	defer server.Close()
+
+	assert.Error(t, fb.Child("users/a.b").Set(true))
+	assert.Error(t, fb.Child("users/a.b").Child("name").Set(true))
+	assert.Error(t, fb.Set(map[string]interface{}{"a": []interface{}{map[string]int{"b#": 1}}}))
+	assert.Error(t, fb.Update(map[string]interface{}{"users/a$": true}))
+	assert.Error(t, fb.SetRawJSON([]byte(`{"a":{"b[":1}}`)))
+	_, err := fb.Push(map[string]bool{"a/b": true})
+	assert.Error(t, err)
+	_, err = fb.Ref("users/a.b")
+	assert.Error(t, err)
+	assert.Len(t, server.receivedReqs, 0)
+
+	require.NoError(t, fb.Child("users").Set(map[string]interface{}{
+		"alice": map[string]interface{}{
+			".value":    "a.b",
+			".priority": 1,
+			"tags":      []interface{}{"$", map[string]interface{}{}},
+		},
+		"bob": map[string]interface{}{"seen": ServerTimestamp},
+	}))
+	require.NoError(t, fb.Update(map[string]interface{}{"users/alice/name": "alice"}))
+	assert.Len(t, server.receivedReqs, 2)
+}
@@ -0,0 +1,90 @@
+package firego
+
+import "sync"
+
+const defaultMultiConcurrency = 8
+
+// SetMultiConcurrency sets how many writes SetMulti performs at once,
+// it defaults to 8.
+func (fb *Firebase) SetMultiConcurrency(n int) {
+	fb.paramsMtx.Lock()
+	fb.multiConcurrency = n
+	fb.paramsMtx.Unlock()
+}
+
+// SetMulti sets the value of every path of the given map, relative to the
+// current reference. Unlike UpdateChildren, the writes are independent:
+// they are made concurrently and a failed write does not prevent the others.
+// The returned map holds the error of every write that failed, it is empty
+// if they all succeeded.
+func (fb *Firebase) SetMulti(writes map[string]interface{}) map[string]error {
+	fb.paramsMtx.RLock()
+	workers := fb.multiConcurrency
+	fb.paramsMtx.RUnlock()
+	if workers <= 0 {
+		workers = defaultMultiConcurrency
+	}
+
+	var (
+		paths = make(chan string)
+		mtx   sync.Mutex
+		errs  = map[string]error{}
+		wg    sync.WaitGroup
+	)
+	for i := 0; i < workers && i < len(writes); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				if err := fb.Child(path).Set(writes[path]); err != nil {
+					mtx.Lock()
+					errs[path] = err
+					mtx.Unlock()
+				}
+			}
+		}()
+	}
+
+	for path := range writes {
+		paths <- path
+	}
+	close(paths)
+	wg.Wait()
+	return errs
+}
+
+// GetAll reads the value of every reference into the target at the same
+// index, making at most concurrency requests at once, or 8 if concurrency
+// is not positive. Each reference uses its own client and credentials.
+// The returned slice holds the error of every read, nil for the ones that
+// succeeded. GetAll panics if there is not one target per reference.
+func GetAll(refs []*Firebase, targets []interface{}, concurrency int) []error {
+	if len(refs) != len(targets) {
+		panic("firego: GetAll needs one target per reference")
+	}
+	if concurrency <= 0 {
+		concurrency = defaultMultiConcurrency
+	}
+
+	var (
+		indexes = make(chan int)
+		errs    = make([]error, len(refs))
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < concurrency && i < len(refs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				errs[i] = refs[i].Value(targets[i])
+			}
+		}()
+	}
+
+	for i := range refs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return errs
+}
@@ -0,0 +1,98 @@
+package firego
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetMulti(t *testing.T) {
+	t.Parallel()
+	var inFlight, maxInFlight int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt64(&inFlight, 1)
+		defer atomic.AddInt64(&inFlight, -1)
+		for {
+			max := atomic.LoadInt64(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt64(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		if strings.HasPrefix(req.URL.Path, "/fail") {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`true`))
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	fb.SetMultiConcurrency(2)
+	errs := fb.SetMulti(map[string]interface{}{
+		"ok1":   true,
+		"ok2":   true,
+		"ok3":   true,
+		"fail1": true,
+		"fail2": true,
+	})
+
+	assert.Len(t, errs, 2)
+	assert.Error(t, errs["fail1"])
+	assert.Error(t, errs["fail2"])
+	assert.Equal(t, int64(2), atomic.LoadInt64(&maxInFlight))
+
+	assert.Empty(t, fb.SetMulti(map[string]interface{}{"ok": true}))
+	assert.Empty(t, fb.SetMulti(nil))
+}
+
+func TestGetAll(t *testing.T) {
+	t.Parallel()
+	var inFlight, maxInFlight int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt64(&inFlight, 1)
+		defer atomic.AddInt64(&inFlight, -1)
+		for {
+			max := atomic.LoadInt64(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt64(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		if req.URL.Query().Get("auth") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, "%q", strings.TrimSuffix(req.URL.Path, "/.json"))
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	fb.Auth("secret")
+	var a, b, c, d string
+	errs := GetAll(
+		[]*Firebase{fb.Child("a"), fb.Child("b"), New(server.URL, nil).Child("c"), fb.Child("d")},
+		[]interface{}{&a, &b, &c, &d},
+		2,
+	)
+
+	require.Len(t, errs, 4)
+	assert.NoError(t, errs[0])
+	assert.NoError(t, errs[1])
+	assert.True(t, IsUnauthorized(errs[2]))
+	assert.NoError(t, errs[3])
+	assert.Equal(t, []string{"/a", "/b", "", "/d"}, []string{a, b, c, d})
+	assert.Equal(t, int64(2), atomic.LoadInt64(&maxInFlight))
+
+	assert.Empty(t, GetAll(nil, nil, 0))
+	assert.Panics(t, func() { GetAll([]*Firebase{fb}, nil, 1) })
+}
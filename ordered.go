@@ -0,0 +1,209 @@
+package firego
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	_url "net/url"
+	"path"
+	"sort"
+)
+
+// OrderedSnapshot holds the children of a Firebase reference in the
+// order of the query that read them, which a Go map cannot preserve.
+type OrderedSnapshot struct {
+	// Key is the key of the location the snapshot was read from.
+	Key string
+
+	keys   []string
+	values []json.RawMessage
+}
+
+// Len returns the number of children of the snapshot.
+func (s *OrderedSnapshot) Len() int {
+	return len(s.keys)
+}
+
+// Keys returns the keys of the children of the snapshot, in order.
+func (s *OrderedSnapshot) Keys() []string {
+	return append([]string(nil), s.keys...)
+}
+
+// ForEach calls fn with every child of the snapshot, in order,
+// until fn returns false.
+func (s *OrderedSnapshot) ForEach(fn func(key string, value json.RawMessage) bool) {
+	for i, key := range s.keys {
+		if !fn(key, s.values[i]) {
+			return
+		}
+	}
+}
+
+// GetOrdered gets the children of the Firebase reference in the order
+// configured with OrderBy, OrderByKey or OrderByValue, children being
+// ordered by key when no order is set. Since Firebase does not order the
+// JSON objects it returns, the children are sorted once read, the same way
+// Firebase does: null first, then false, true, numbers, strings and objects,
+// children with the same value are sorted by key. Children ordered by
+// priority keep the order in which they were received.
+//
+// A snapshot of a location holding a primitive value has no children.
+func (fb *Firebase) GetOrdered() (*OrderedSnapshot, error) {
+	_, body, err := fb.doRequest(context.Background(), "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := decodeOrdered(body)
+	if err != nil {
+		return nil, err
+	}
+	if u, err := _url.Parse(fb.url); err == nil && u.Path != "" {
+		snapshot.Key = path.Base(u.Path)
+	}
+
+	fb.paramsMtx.RLock()
+	orderBy := fb.params.Get(orderByParam)
+	fb.paramsMtx.RUnlock()
+	by := orderByKey
+	if orderBy != "" {
+		if err := json.Unmarshal([]byte(orderBy), &by); err != nil {
+			return nil, fmt.Errorf("invalid orderBy %s: %s", orderBy, err)
+		}
+	}
+	if by != orderByPriority {
+		sort.Stable(snapshotSorter{snapshot: snapshot, by: by})
+	}
+	return snapshot, nil
+}
+
+// GetSorted gets the children of the Firebase reference and sorts their
+// values with the given less function, which allows orders Firebase does
+// not support, such as on several fields. Every child matching the query
+// of the reference is downloaded and sorted once read: nothing is sorted
+// nor limited on the server beyond what the query itself does.
+// Children that are equal according to less keep the order of their keys.
+func (fb *Firebase) GetSorted(less func(a, b json.RawMessage) bool) ([]json.RawMessage, error) {
+	_, body, err := fb.doRequest(context.Background(), "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := decodeOrdered(body)
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(snapshotSorter{snapshot: snapshot, by: orderByKey})
+
+	values := snapshot.values
+	sort.SliceStable(values, func(i, j int) bool {
+		return less(values[i], values[j])
+	})
+	return values, nil
+}
+
+// decodeOrdered reads the children of the given JSON data
+// in the order they appear in.
+func decodeOrdered(data []byte) (*OrderedSnapshot, error) {
+	snapshot := &OrderedSnapshot{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok != json.Delim('{') {
+		return snapshot, nil
+	}
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid key %v", tok)
+		}
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		snapshot.keys = append(snapshot.keys, key)
+		snapshot.values = append(snapshot.values, value)
+	}
+	return snapshot, nil
+}
+
+type snapshotSorter struct {
+	snapshot *OrderedSnapshot
+	by       string
+}
+
+func (s snapshotSorter) Len() int {
+	return s.snapshot.Len()
+}
+
+func (s snapshotSorter) Swap(i, j int) {
+	keys, values := s.snapshot.keys, s.snapshot.values
+	keys[i], keys[j] = keys[j], keys[i]
+	values[i], values[j] = values[j], values[i]
+}
+
+func (s snapshotSorter) Less(i, j int) bool {
+	keys, values := s.snapshot.keys, s.snapshot.values
+	if s.by != orderByKey {
+		if c := compareValues(orderedValue(values[i], s.by), orderedValue(values[j], s.by)); c != 0 {
+			return c < 0
+		}
+	}
+	return compareKeys(keys[i], keys[j]) < 0
+}
+
+// compareValues orders decoded JSON values the way Firebase does.
+func compareValues(a, b interface{}) int {
+	if ra, rb := valueRank(a), valueRank(b); ra != rb {
+		if ra < rb {
+			return -1
+		}
+		return 1
+	}
+
+	switch a := a.(type) {
+	case float64:
+		b := b.(float64)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+	case string:
+		b := b.(string)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+	}
+	return 0
+}
+
+func valueRank(v interface{}) int {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case bool:
+		if v {
+			return 2
+		}
+		return 1
+	case float64:
+		return 3
+	case string:
+		return 4
+	}
+	return 5
+}
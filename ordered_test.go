@@ -0,0 +1,108 @@
+package firego
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetOrdered(t *testing.T) {
+	t.Parallel()
+	var (
+		server = newTestServer(`{
+			"d": {"age": 30},
+			"b": {"age": 30},
+			"e": {"age": "unknown"},
+			"a": {"age": 42},
+			"c": {},
+			"10": {"age": 1},
+			"9": {"age": true}
+		}`)
+		fb = New(server.URL, nil).Child("users")
+	)
+	defer server.Close()
+
+	snapshot, err := fb.GetOrdered()
+	require.NoError(t, err)
+	assert.Equal(t, "users", snapshot.Key)
+	assert.Equal(t, []string{"9", "10", "a", "b", "c", "d", "e"}, snapshot.Keys())
+
+	snapshot, err = fb.OrderBy("age").GetOrdered()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "9", "10", "b", "d", "a", "e"}, snapshot.Keys())
+
+	var visited []string
+	snapshot.ForEach(func(key string, value json.RawMessage) bool {
+		visited = append(visited, key)
+		if key == "b" {
+			assert.JSONEq(t, `{"age": 30}`, string(value))
+		}
+		return key != "b"
+	})
+	assert.Equal(t, []string{"c", "9", "10", "b"}, visited)
+}
+
+func TestGetOrderedValue(t *testing.T) {
+	t.Parallel()
+	server := newTestServer(`{"a": "y", "b": 2, "c": "x", "d": null, "e": false, "f": {"g": 1}}`)
+	defer server.Close()
+
+	snapshot, err := New(server.URL, nil).OrderByValue().GetOrdered()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"d", "e", "b", "c", "a", "f"}, snapshot.Keys())
+	assert.Equal(t, 6, snapshot.Len())
+}
+
+func TestGetOrderedPrimitive(t *testing.T) {
+	t.Parallel()
+	server := newTestServer(`"foo"`)
+	defer server.Close()
+
+	snapshot, err := New(server.URL, nil).GetOrdered()
+	require.NoError(t, err)
+	assert.Equal(t, 0, snapshot.Len())
+	snapshot.ForEach(func(string, json.RawMessage) bool {
+		t.Fatal("primitive values have no children")
+		return true
+	})
+}
+
+func TestGetSorted(t *testing.T) {
+	t.Parallel()
+	server := newTestServer(`{
+		"d": {"last": "Doe", "first": "John"},
+		"a": {"last": "Smith", "first": "Anna"},
+		"c": {"last": "Doe", "first": "Jane"},
+		"b": {"last": "Doe", "first": "Jane"}
+	}`)
+	defer server.Close()
+
+	type person struct {
+		Last  string `json:"last"`
+		First string `json:"first"`
+	}
+	decode := func(data json.RawMessage) person {
+		var p person
+		require.NoError(t, json.Unmarshal(data, &p))
+		return p
+	}
+
+	values, err := New(server.URL, nil).GetSorted(func(a, b json.RawMessage) bool {
+		pa, pb := decode(a), decode(b)
+		if pa.Last != pb.Last {
+			return pa.Last < pb.Last
+		}
+		return pa.First < pb.First
+	})
+	require.NoError(t, err)
+	require.Len(t, values, 4)
+
+	var names []string
+	for _, value := range values {
+		p := decode(value)
+		names = append(names, p.First+" "+p.Last)
+	}
+	assert.Equal(t, []string{"Jane Doe", "Jane Doe", "John Doe", "Anna Smith"}, names)
+}
@@ -0,0 +1,74 @@
+package firego
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// pushChars are the characters used in push IDs, they are ordered
+// by ASCII value so that generated IDs sort lexicographically.
+const pushChars = "-0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ_abcdefghijklmnopqrstuvwxyz"
+
+var pushIDs = struct {
+	sync.Mutex
+	rnd       *rand.Rand
+	lastTime  int64
+	lastRands [12]int
+}{
+	rnd: rand.New(rand.NewSource(time.Now().UnixNano())),
+}
+
+// GenerateKey generates a Firebase style push ID locally. The first 8
+// characters encode the current time in milliseconds and the remaining 12
+// are random, so keys sort chronologically. Keys generated within the same
+// millisecond by this process are still strictly increasing.
+//
+// The key can be given to Child so data is written with Set under a
+// location that is known before any request is made.
+//
+// Reference https://firebase.googleblog.com/2015/02/the-2120-ways-to-ensure-unique_68.html
+func GenerateKey() string {
+	pushIDs.Lock()
+	defer pushIDs.Unlock()
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	if now < pushIDs.lastTime {
+		// the clock went backwards, keep keys increasing
+		now = pushIDs.lastTime
+	}
+	duplicateTime := now == pushIDs.lastTime
+	pushIDs.lastTime = now
+
+	var id [20]byte
+	for i := 7; i >= 0; i-- {
+		id[i] = pushChars[now%64]
+		now /= 64
+	}
+
+	if !duplicateTime {
+		for i := range pushIDs.lastRands {
+			pushIDs.lastRands[i] = pushIDs.rnd.Intn(64)
+		}
+	} else {
+		// same millisecond as the last key, increment the
+		// random characters by one to keep keys ordered
+		i := len(pushIDs.lastRands) - 1
+		for ; i >= 0 && pushIDs.lastRands[i] == 63; i-- {
+			pushIDs.lastRands[i] = 0
+		}
+		if i >= 0 {
+			pushIDs.lastRands[i]++
+		}
+	}
+
+	for i, r := range pushIDs.lastRands {
+		id[8+i] = pushChars[r]
+	}
+	return string(id[:])
+}
+
+// NewPushID is an alias of GenerateKey.
+func NewPushID() string {
+	return GenerateKey()
+}
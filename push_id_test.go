@@ -0,0 +1,79 @@
+package firego
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trevor403/firego/firetest"
+)
+
+func TestGenerateKey(t *testing.T) {
+	t.Parallel()
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = GenerateKey()
+	}
+
+	seen := map[string]bool{}
+	for _, k := range keys {
+		require.Len(t, k, 20)
+		for _, c := range k {
+			assert.True(t, strings.ContainsRune(pushChars, c), "unexpected character %q in %s", c, k)
+		}
+		assert.False(t, seen[k], "duplicate key %s", k)
+		seen[k] = true
+	}
+	assert.True(t, sort.StringsAreSorted(keys), "keys are not monotonic")
+}
+
+func TestGenerateKeyTimestamp(t *testing.T) {
+	t.Parallel()
+	before := GenerateKey()
+	time.Sleep(2 * time.Millisecond)
+	after := GenerateKey()
+
+	assert.True(t, before[:8] < after[:8])
+}
+
+func TestGenerateKeyClockBackwards(t *testing.T) {
+	// not parallel since it changes the state of the generator
+	pushIDs.Lock()
+	last := pushIDs.lastTime
+	pushIDs.lastTime = time.Now().Add(time.Hour).UnixNano() / int64(time.Millisecond)
+	pushIDs.Unlock()
+	defer func() {
+		pushIDs.Lock()
+		pushIDs.lastTime = last
+		pushIDs.Unlock()
+	}()
+
+	first, second := GenerateKey(), GenerateKey()
+	assert.Equal(t, first[:8], second[:8])
+	assert.True(t, first < second)
+}
+
+func TestPushWithKey(t *testing.T) {
+	t.Parallel()
+	server := firetest.New()
+	server.Start()
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	before := NewPushID()
+	key, ref, err := fb.Child("list").PushWithKey("foo")
+	require.NoError(t, err)
+	assert.True(t, before < key)
+	assert.Equal(t, fb.url+"/list/"+key, ref.url)
+	assert.Equal(t, "foo", server.Get("list/"+key))
+
+	// the reference is known even if the write fails
+	fb.SetMaxWriteSize(1)
+	key, ref, err = fb.PushWithKey("foo")
+	assert.Error(t, err)
+	assert.Len(t, key, 20)
+	assert.Equal(t, fb.url+"/"+key, ref.url)
+}
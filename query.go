@@ -1,11 +1,25 @@
 package firego
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	_url "net/url"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 )
 
+// special orderBy values
+const (
+	orderByKey      = "$key"
+	orderByValue    = "$value"
+	orderByPriority = "$priority"
+)
+
 // StartAt creates a new Firebase reference with the
 // requested StartAt configuration. The value that is passed in
 // is automatically escaped if it is a string value.
@@ -26,6 +40,7 @@ func (fb *Firebase) StartAt(value string) *Firebase {
 	} else {
 		c.params.Del(startAtParam)
 	}
+	c.startAtKey = ""
 	return c
 }
 
@@ -49,6 +64,7 @@ func (fb *Firebase) StartAtValue(value interface{}) *Firebase {
 	} else {
 		c.params.Del(startAtParam)
 	}
+	c.startAtKey = ""
 	return c
 }
 
@@ -72,6 +88,7 @@ func (fb *Firebase) EndAt(value string) *Firebase {
 	} else {
 		c.params.Del(endAtParam)
 	}
+	c.endAtKey = ""
 	return c
 }
 
@@ -95,16 +112,123 @@ func (fb *Firebase) EndAtValue(value interface{}) *Firebase {
 	} else {
 		c.params.Del(endAtParam)
 	}
+	c.endAtKey = ""
+	return c
+}
+
+// StartAtKey creates a new Firebase reference starting at the given value,
+// like StartAtValue, and, among the children whose ordered value is equal
+// to it, at the child with the given key. This allows paging through
+// children sharing the same value without repeating or skipping any.
+//
+//    OrderBy("age").StartAtKey(30, "bob") // -> startAt=30, from key "bob"
+//
+// The REST API has no key argument so the children before the key are
+// filtered out once read: they are still counted by LimitToFirst, which
+// should be large enough for a page to hold more than the children
+// sharing the value. Ordering by priority is not supported.
+func (fb *Firebase) StartAtKey(value interface{}, key string) *Firebase {
+	c := fb.StartAtValue(value)
+	c.startAtKey = key
 	return c
 }
 
+// EndAtKey creates a new Firebase reference ending at the given value,
+// like EndAtValue, and, among the children whose ordered value is equal
+// to it, at the child with the given key. See StartAtKey.
+//
+//    OrderBy("age").EndAtKey(30, "bob") // -> endAt=30, up to key "bob"
+func (fb *Firebase) EndAtKey(value interface{}, key string) *Firebase {
+	c := fb.EndAtValue(value)
+	c.endAtKey = key
+	return c
+}
+
+// filterKeyRange removes the children of the given data that are outside
+// of the range of keys set with StartAtKey and EndAtKey.
+func (fb *Firebase) filterKeyRange(data []byte) ([]byte, error) {
+	fb.paramsMtx.RLock()
+	var (
+		orderBy  = fb.params.Get(orderByParam)
+		startAt  = fb.params.Get(startAtParam)
+		endAt    = fb.params.Get(endAtParam)
+		startKey = fb.startAtKey
+		endKey   = fb.endAtKey
+	)
+	fb.paramsMtx.RUnlock()
+	if startKey == "" && endKey == "" {
+		return data, nil
+	}
+
+	var path string
+	if err := json.Unmarshal([]byte(orderBy), &path); err != nil {
+		return nil, fmt.Errorf("invalid orderBy %s: %s", orderBy, err)
+	}
+	switch path {
+	case orderByKey:
+		// keys are unique, the range already starts or ends at the key
+		return data, nil
+	case orderByPriority:
+		return nil, errors.New("startAt and endAt keys cannot be used when ordering by priority")
+	}
+
+	var children map[string]json.RawMessage
+	if err := json.Unmarshal(data, &children); err != nil {
+		// not a list of children, there is nothing to filter
+		return data, nil
+	}
+
+	startValue, endValue := decodeParam(startAt), decodeParam(endAt)
+	for key, child := range children {
+		value := orderedValue(child, path)
+		if startKey != "" && reflect.DeepEqual(value, startValue) && compareKeys(key, startKey) < 0 {
+			delete(children, key)
+		}
+		if endKey != "" && reflect.DeepEqual(value, endValue) && compareKeys(key, endKey) > 0 {
+			delete(children, key)
+		}
+	}
+	return json.Marshal(children)
+}
+
+func decodeParam(param string) interface{} {
+	var v interface{}
+	json.Unmarshal([]byte(param), &v)
+	return v
+}
+
+// orderedValue returns the value of the given child children are ordered by,
+// either the child itself or its descendant at path.
+func orderedValue(child json.RawMessage, path string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal(child, &v); err != nil {
+		return nil
+	}
+	if path == orderByValue {
+		return v
+	}
+	for _, key := range strings.Split(path, "/") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
 // OrderBy creates a new Firebase reference with the
 // requested OrderBy configuration. The value that is passed in
-// is automatically escaped if it is a string value.
+// is always quoted since Firebase expects the name of a child key,
+// even when that name looks like a number.
 //
 //    OrderBy("foo")   // -> orderBy="foo"
 //    OrderBy(`"foo"`) // -> orderBy="foo"
 //    OrderBy("$key")  // -> orderBy="$key"
+//    OrderBy("7")     // -> orderBy="7"
+//
+// Like the other query functions, the configuration only affects
+// reads (Value/Get) made with the returned reference.
 //
 // Reference https://firebase.google.com/docs/database/rest/retrieve-data#orderby
 func (fb *Firebase) OrderBy(value string) *Firebase {
@@ -112,13 +236,43 @@ func (fb *Firebase) OrderBy(value string) *Firebase {
 	// explicitly not locking here because no one else can
 	// modify this value before we return it.
 	if value != "" {
-		c.params.Set(orderByParam, escapeString(value))
+		c.params.Set(orderByParam, escapeParameter(value))
 	} else {
 		c.params.Del(orderByParam)
 	}
 	return c
 }
 
+// OrderByKey creates a new Firebase reference ordering
+// children by their key.
+//
+//    OrderByKey() // -> orderBy="$key"
+//
+// Reference https://firebase.google.com/docs/database/rest/retrieve-data#orderby
+func (fb *Firebase) OrderByKey() *Firebase {
+	return fb.OrderBy(orderByKey)
+}
+
+// OrderByValue creates a new Firebase reference ordering
+// children by their value.
+//
+//    OrderByValue() // -> orderBy="$value"
+//
+// Reference https://firebase.google.com/docs/database/rest/retrieve-data#orderby
+func (fb *Firebase) OrderByValue() *Firebase {
+	return fb.OrderBy(orderByValue)
+}
+
+// OrderByPriority creates a new Firebase reference ordering
+// children by their priority. See SetWithPriority.
+//
+//    OrderByPriority() // -> orderBy="$priority"
+//
+// Reference https://firebase.google.com/docs/database/rest/retrieve-data#orderby
+func (fb *Firebase) OrderByPriority() *Firebase {
+	return fb.OrderBy(orderByPriority)
+}
+
 // EqualTo sends the query string equalTo so that one can find nodes with
 // exactly matching values. The value that is passed in is automatically escaped
 // if it is a string value.
@@ -145,10 +299,13 @@ func (fb *Firebase) EqualTo(value string) *Firebase {
 // EqualToValue sends the query string equalTo so that one can find nodes with
 // exactly matching values. The value that is passed in is automatically escaped
 // if it is a string value.
-// Numeric strings are preserved as strings.
+// Numeric strings are preserved as strings. The value must have the
+// type of the ordered values for children to match, and the reference
+// must be ordered, see OrderBy.
 //
 //    EqualToValue(7)        // -> equalTo=7
 //    EqualToValue("7")      // -> equalTo="7"
+//    EqualToValue(true)     // -> equalTo=true
 //    EqualToValue("foo")    // -> equalTo="foo"
 //    EqualToValue(`"foo"`)  // -> equalTo="foo"
 //
@@ -175,13 +332,20 @@ func escapeString(s string) string {
 	return fmt.Sprintf(`%q`, strings.Trim(s, `"`))
 }
 
+// escapeParameter encodes the given value as JSON, which is how Firebase
+// expects query values: strings quoted, numbers and booleans bare.
 func escapeParameter(s interface{}) string {
-	switch s.(type) {
-	case string:
-		return fmt.Sprintf(`%q`, strings.Trim(s.(string), `"`))
-	default:
+	if str, ok := s.(string); ok {
+		s = strings.Trim(str, `"`)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
 		return fmt.Sprintf(`%v`, s)
 	}
+	return strings.TrimSuffix(buf.String(), "\n")
 }
 
 // LimitToFirst creates a new Firebase reference with the
@@ -232,6 +396,57 @@ func (fb *Firebase) Shallow(v bool) {
 	fb.paramsMtx.Unlock()
 }
 
+// ShallowRef creates a new Firebase reference with the shallow
+// configuration set, leaving the current reference untouched.
+// See Shallow for more information.
+//
+// Reference https://firebase.google.com/docs/database/rest/retrieve-data#shallow
+func (fb *Firebase) ShallowRef() *Firebase {
+	c := fb.copy()
+	// explicitly not locking here because no one else can
+	// modify this value before we return it.
+	c.params.Set(shallowParam, "true")
+	return c
+}
+
+// Keys returns the keys of the children of the reference, in the order
+// Firebase sorts keys, without downloading the children themselves. A
+// location holding a primitive value, or no value, has no children.
+// See ShallowRef. Firebase does not filter shallow reads, the keys of a
+// reference with a query, such as LimitToFirst, are those of the children
+// it reads, which are downloaded.
+func (fb *Firebase) Keys() ([]string, error) {
+	ref := fb.ShallowRef()
+	if validateQuery(ref.params) != nil {
+		ref = fb
+	}
+	_, body, err := ref.doRequest(context.Background(), "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var children map[string]json.RawMessage
+	if err := json.Unmarshal(body, &children); err != nil {
+		// not an object, there are no children
+		return nil, nil
+	}
+	keys := make([]string, 0, len(children))
+	for key := range children {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return compareKeys(keys[i], keys[j]) < 0
+	})
+	return keys, nil
+}
+
+// Count returns the number of children of the reference without
+// downloading them. See Keys.
+func (fb *Firebase) Count() (int, error) {
+	keys, err := fb.Keys()
+	return len(keys), err
+}
+
 // IncludePriority determines whether or not to ask Firebase
 // for the values priority. By default, the priority is not returned.
 //
@@ -245,3 +460,130 @@ func (fb *Firebase) IncludePriority(v bool) {
 	}
 	fb.paramsMtx.Unlock()
 }
+
+// Params returns a copy of the query parameters of the reference,
+// including its credentials.
+func (fb *Firebase) Params() _url.Values {
+	fb.paramsMtx.RLock()
+	defer fb.paramsMtx.RUnlock()
+
+	params := _url.Values{}
+	for k, v := range fb.params {
+		params[k] = append([]string(nil), v...)
+	}
+	return params
+}
+
+// ClearParams removes every query parameter of the reference, such as
+// filters, except for its credentials, its auth override and the namespace
+// of emulator URLs.
+func (fb *Firebase) ClearParams() {
+	fb.paramsMtx.Lock()
+	for k := range fb.params {
+		if k != authParam && k != authOverrideParam && k != nsParam {
+			delete(fb.params, k)
+		}
+	}
+	fb.startAtKey, fb.endAtKey = "", ""
+	fb.paramsMtx.Unlock()
+}
+
+// Query describes all the filters of a query at once. It is a typed
+// alternative to chaining the query functions, see Firebase.Query.
+// Nil values and zero limits are left out of the query.
+type Query struct {
+	OrderBy      string
+	StartAt      interface{}
+	EndAt        interface{}
+	EqualTo      interface{}
+	LimitToFirst int
+	LimitToLast  int
+	Shallow      bool
+}
+
+// queryParams lists the parameters making up a query.
+var queryParams = []string{
+	orderByParam,
+	startAtParam,
+	endAtParam,
+	equalToParam,
+	limitToFirstParam,
+	limitToLastParam,
+	shallowParam,
+}
+
+// Query creates a new Firebase reference with the filters of the given
+// query, replacing any query configured on the current reference. Values
+// are escaped like with StartAtValue, numeric strings are preserved as
+// strings. An error is returned for combinations Firebase does not support.
+//
+//	Query(Query{OrderBy: "$key", StartAt: "a", LimitToFirst: 10})
+//	// -> orderBy="$key"&startAt="a"&limitToFirst=10
+//
+// Reference https://firebase.google.com/docs/database/rest/retrieve-data#section-rest-filtering
+func (fb *Firebase) Query(q Query) (*Firebase, error) {
+	c := fb.copy()
+	// explicitly not locking here because no one else can
+	// modify this value before we return it.
+	for _, param := range queryParams {
+		c.params.Del(param)
+	}
+	c.startAtKey, c.endAtKey = "", ""
+	if q.OrderBy != "" {
+		c.params.Set(orderByParam, escapeParameter(q.OrderBy))
+	}
+	if q.StartAt != nil {
+		c.params.Set(startAtParam, escapeParameter(q.StartAt))
+	}
+	if q.EndAt != nil {
+		c.params.Set(endAtParam, escapeParameter(q.EndAt))
+	}
+	if q.EqualTo != nil {
+		c.params.Set(equalToParam, escapeParameter(q.EqualTo))
+	}
+	if q.LimitToFirst > 0 {
+		c.params.Set(limitToFirstParam, strconv.Itoa(q.LimitToFirst))
+	}
+	if q.LimitToLast > 0 {
+		c.params.Set(limitToLastParam, strconv.Itoa(q.LimitToLast))
+	}
+	if q.Shallow {
+		c.params.Set(shallowParam, "true")
+	}
+
+	if err := validateQuery(c.params); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// validateQuery returns an error describing the first combination
+// of query parameters rejected by Firebase.
+func validateQuery(params _url.Values) error {
+	has := func(param string) bool {
+		_, ok := params[param]
+		return ok
+	}
+
+	if has(limitToFirstParam) && has(limitToLastParam) {
+		return errors.New("limitToFirst and limitToLast cannot be used together")
+	}
+	if has(equalToParam) && (has(startAtParam) || has(endAtParam)) {
+		return errors.New("equalTo cannot be used with startAt or endAt")
+	}
+	if !has(orderByParam) {
+		for _, param := range []string{startAtParam, endAtParam, equalToParam, limitToFirstParam, limitToLastParam} {
+			if has(param) {
+				return fmt.Errorf("%s requires orderBy", param)
+			}
+		}
+	}
+	if has(shallowParam) {
+		for _, param := range queryParams {
+			if param != shallowParam && has(param) {
+				return fmt.Errorf("shallow cannot be used with %s", param)
+			}
+		}
+	}
+	return nil
+}
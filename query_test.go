@@ -1,6 +1,8 @@
 package firego
 
 import (
+	"encoding/json"
+	_url "net/url"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -30,6 +32,114 @@ func TestShallow(t *testing.T) {
 	assert.Equal(t, "", req.URL.Query().Encode())
 }
 
+func TestShallowRef(t *testing.T) {
+	t.Parallel()
+	var (
+		server = newTestServer("")
+		fb     = New(server.URL, nil)
+	)
+	defer server.Close()
+
+	authed := fb.Child("bar")
+	authed.Auth("token")
+	authed.ShallowRef().Value("")
+	shallow := fb.ShallowRef()
+	shallow.Value("")
+	shallow.Child("baz").Value("")
+	fb.Value("")
+	require.Len(t, server.receivedReqs, 4)
+
+	req := server.receivedReqs[0]
+	assert.Equal(t, "/bar/.json", req.URL.Path)
+	assert.Equal(t, "true", req.URL.Query().Get(shallowParam))
+	assert.Equal(t, "token", req.URL.Query().Get(authParam))
+
+	req = server.receivedReqs[1]
+	assert.Equal(t, shallowParam+"=true", req.URL.Query().Encode())
+
+	req = server.receivedReqs[2]
+	assert.Equal(t, "/baz/.json", req.URL.Path)
+	assert.Equal(t, shallowParam+"=true", req.URL.Query().Encode())
+
+	// the original reference keeps its own params
+	req = server.receivedReqs[3]
+	assert.Equal(t, "", req.URL.Query().Encode())
+	assert.Len(t, fb.params, 0)
+}
+
+func TestParams(t *testing.T) {
+	t.Parallel()
+	var (
+		server = newTestServer(`null`)
+		fb     = New(server.URL+"?ns=project", nil)
+	)
+	defer server.Close()
+
+	fb.Auth("token")
+	query := fb.OrderBy("age").StartAtKey(30, "bob").LimitToFirst(5)
+	params := query.Params()
+	assert.Equal(t, _url.Values{
+		authParam:         {"token"},
+		nsParam:           {"project"},
+		orderByParam:      {`"age"`},
+		startAtParam:      {`30`},
+		limitToFirstParam: {"5"},
+	}, params)
+
+	// the copy does not affect the reference
+	params.Del(authParam)
+	assert.Equal(t, "token", query.Params().Get(authParam))
+
+	query.ClearParams()
+	assert.Equal(t, _url.Values{authParam: {"token"}, nsParam: {"project"}}, query.Params())
+	assert.Empty(t, query.startAtKey)
+
+	var v interface{}
+	require.NoError(t, query.Value(&v))
+	require.Len(t, server.receivedReqs, 1)
+	assert.Equal(t, "auth=token&ns=project", server.receivedReqs[0].URL.RawQuery)
+}
+
+func TestKeys(t *testing.T) {
+	t.Parallel()
+	var (
+		server = newTestServer(`{"b": true, "10": true, "a": true, "9": true}`)
+		fb     = New(server.URL, nil)
+	)
+	defer server.Close()
+
+	keys, err := fb.Keys()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"9", "10", "a", "b"}, keys)
+	require.Len(t, server.receivedReqs, 1)
+	assert.Equal(t, "true", server.receivedReqs[0].URL.Query().Get(shallowParam))
+
+	count, err := fb.Count()
+	require.NoError(t, err)
+	assert.Equal(t, 4, count)
+
+	// shallow reads cannot be filtered, the filtered children are read
+	keys, err = fb.OrderBy("x").LimitToFirst(2).Keys()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"9", "10", "a", "b"}, keys)
+	require.Len(t, server.receivedReqs, 3)
+	query := server.receivedReqs[2].URL.Query()
+	assert.Empty(t, query.Get(shallowParam))
+	assert.Equal(t, `"x"`, query.Get(orderByParam))
+	assert.Equal(t, "2", query.Get(limitToFirstParam))
+}
+
+func TestKeysNoChildren(t *testing.T) {
+	t.Parallel()
+	for _, response := range []string{`null`, `"foo"`, `42`} {
+		server := newTestServer(response)
+		count, err := New(server.URL, nil).Count()
+		server.Close()
+		require.NoError(t, err)
+		assert.Equal(t, 0, count, response)
+	}
+}
+
 func TestOrderBy(t *testing.T) {
 	t.Parallel()
 	var (
@@ -43,13 +153,25 @@ func TestOrderBy(t *testing.T) {
 
 	req := server.receivedReqs[0]
 	assert.Equal(t, orderByParam+"=%22user_id%22", req.URL.Query().Encode())
+
+	fb.OrderBy("7").Value("")
+	fb.OrderBy("true").Value("")
+	fb.OrderBy(`"$key"`).Value("")
+	require.Len(t, server.receivedReqs, 4)
+
+	assert.Equal(t, `"7"`, server.receivedReqs[1].URL.Query().Get(orderByParam))
+	assert.Equal(t, `"true"`, server.receivedReqs[2].URL.Query().Get(orderByParam))
+	assert.Equal(t, `"$key"`, server.receivedReqs[3].URL.Query().Get(orderByParam))
+
+	// the original reference is untouched
+	assert.Len(t, fb.params, 0)
 }
 
 func TestEqualTo(t *testing.T) {
 	t.Parallel()
 	var (
 		server = newTestServer("")
-		fb     = New(server.URL, nil)
+		fb     = New(server.URL, nil).OrderByKey()
 	)
 	defer server.Close()
 
@@ -57,14 +179,14 @@ func TestEqualTo(t *testing.T) {
 	require.Len(t, server.receivedReqs, 1)
 
 	req := server.receivedReqs[0]
-	assert.Equal(t, equalToParam+"=%22user_id%22", req.URL.Query().Encode())
+	assert.Equal(t, `"user_id"`, req.URL.Query().Get(equalToParam))
 }
 
 func TestEqualToValue(t *testing.T) {
 	t.Parallel()
 	var (
 		server = newTestServer("")
-		fb     = New(server.URL, nil)
+		fb     = New(server.URL, nil).OrderByKey()
 	)
 	defer server.Close()
 
@@ -72,19 +194,31 @@ func TestEqualToValue(t *testing.T) {
 	fb.EqualToValue("2").Value("")
 	fb.EqualToValue(2.14).Value("")
 	fb.EqualToValue("bar").Value("")
-	require.Len(t, server.receivedReqs, 4)
+	fb.EqualToValue(true).Value("")
+	fb.EqualToValue("true").Value("")
+	require.Len(t, server.receivedReqs, 6)
 
 	req := server.receivedReqs[0]
-	assert.Equal(t, equalToParam+"=2", req.URL.Query().Encode())
+	assert.Equal(t, `2`, req.URL.Query().Get(equalToParam))
 
 	req = server.receivedReqs[1]
-	assert.Equal(t, equalToParam+"=%222%22", req.URL.Query().Encode())
+	assert.Equal(t, `"2"`, req.URL.Query().Get(equalToParam))
 
 	req = server.receivedReqs[2]
-	assert.Equal(t, equalToParam+"=2.14", req.URL.Query().Encode())
+	assert.Equal(t, `2.14`, req.URL.Query().Get(equalToParam))
 
 	req = server.receivedReqs[3]
-	assert.Equal(t, equalToParam+"=%22bar%22", req.URL.Query().Encode())
+	assert.Equal(t, `"bar"`, req.URL.Query().Get(equalToParam))
+
+	req = server.receivedReqs[4]
+	assert.Equal(t, `true`, req.URL.Query().Get(equalToParam))
+
+	req = server.receivedReqs[5]
+	assert.Equal(t, `"true"`, req.URL.Query().Get(equalToParam))
+
+	// the reference must be ordered
+	assert.EqualError(t, New(server.URL, nil).EqualToValue(true).Value(""), "equalTo requires orderBy")
+	assert.Len(t, server.receivedReqs, 6)
 
 }
 
@@ -92,7 +226,7 @@ func TestLimitToFirst(t *testing.T) {
 	t.Parallel()
 	var (
 		server = newTestServer("")
-		fb     = New(server.URL, nil)
+		fb     = New(server.URL, nil).OrderByKey()
 	)
 	defer server.Close()
 
@@ -100,14 +234,14 @@ func TestLimitToFirst(t *testing.T) {
 	require.Len(t, server.receivedReqs, 1)
 
 	req := server.receivedReqs[0]
-	assert.Equal(t, limitToFirstParam+"=2", req.URL.Query().Encode())
+	assert.Equal(t, `2`, req.URL.Query().Get(limitToFirstParam))
 }
 
 func TestLimitToLast(t *testing.T) {
 	t.Parallel()
 	var (
 		server = newTestServer("")
-		fb     = New(server.URL, nil)
+		fb     = New(server.URL, nil).OrderByKey()
 	)
 	defer server.Close()
 
@@ -115,14 +249,14 @@ func TestLimitToLast(t *testing.T) {
 	require.Len(t, server.receivedReqs, 1)
 
 	req := server.receivedReqs[0]
-	assert.Equal(t, limitToLastParam+"=2", req.URL.Query().Encode())
+	assert.Equal(t, `2`, req.URL.Query().Get(limitToLastParam))
 }
 
 func TestStartAt(t *testing.T) {
 	t.Parallel()
 	var (
 		server = newTestServer("")
-		fb     = New(server.URL, nil)
+		fb     = New(server.URL, nil).OrderByKey()
 	)
 	defer server.Close()
 
@@ -131,17 +265,17 @@ func TestStartAt(t *testing.T) {
 	require.Len(t, server.receivedReqs, 2)
 
 	req := server.receivedReqs[0]
-	assert.Equal(t, startAtParam+"=3", req.URL.Query().Encode())
+	assert.Equal(t, `3`, req.URL.Query().Get(startAtParam))
 
 	req = server.receivedReqs[1]
-	assert.Equal(t, startAtParam+"=%22foo%22", req.URL.Query().Encode())
+	assert.Equal(t, `"foo"`, req.URL.Query().Get(startAtParam))
 }
 
 func TestStartAtValue(t *testing.T) {
 	t.Parallel()
 	var (
 		server = newTestServer("")
-		fb     = New(server.URL, nil)
+		fb     = New(server.URL, nil).OrderByKey()
 	)
 	defer server.Close()
 
@@ -152,23 +286,23 @@ func TestStartAtValue(t *testing.T) {
 	require.Len(t, server.receivedReqs, 4)
 
 	req := server.receivedReqs[0]
-	assert.Equal(t, startAtParam+"=3", req.URL.Query().Encode())
+	assert.Equal(t, `3`, req.URL.Query().Get(startAtParam))
 
 	req = server.receivedReqs[1]
-	assert.Equal(t, startAtParam+"=%223%22", req.URL.Query().Encode())
+	assert.Equal(t, `"3"`, req.URL.Query().Get(startAtParam))
 
 	req = server.receivedReqs[2]
-	assert.Equal(t, startAtParam+"=3.14", req.URL.Query().Encode())
+	assert.Equal(t, `3.14`, req.URL.Query().Get(startAtParam))
 
 	req = server.receivedReqs[3]
-	assert.Equal(t, startAtParam+"=%22foo%22", req.URL.Query().Encode())
+	assert.Equal(t, `"foo"`, req.URL.Query().Get(startAtParam))
 }
 
 func TestEndAt(t *testing.T) {
 	t.Parallel()
 	var (
 		server = newTestServer("")
-		fb     = New(server.URL, nil)
+		fb     = New(server.URL, nil).OrderByKey()
 	)
 	defer server.Close()
 
@@ -177,17 +311,17 @@ func TestEndAt(t *testing.T) {
 	require.Len(t, server.receivedReqs, 2)
 
 	req := server.receivedReqs[0]
-	assert.Equal(t, endAtParam+"=4", req.URL.Query().Encode())
+	assert.Equal(t, `4`, req.URL.Query().Get(endAtParam))
 
 	req = server.receivedReqs[1]
-	assert.Equal(t, endAtParam+"=%22theend%22", req.URL.Query().Encode())
+	assert.Equal(t, `"theend"`, req.URL.Query().Get(endAtParam))
 }
 
 func TestEndAtValue(t *testing.T) {
 	t.Parallel()
 	var (
 		server = newTestServer("")
-		fb     = New(server.URL, nil)
+		fb     = New(server.URL, nil).OrderByKey()
 	)
 	defer server.Close()
 
@@ -198,16 +332,16 @@ func TestEndAtValue(t *testing.T) {
 	require.Len(t, server.receivedReqs, 4)
 
 	req := server.receivedReqs[0]
-	assert.Equal(t, endAtParam+"=4", req.URL.Query().Encode())
+	assert.Equal(t, `4`, req.URL.Query().Get(endAtParam))
 
 	req = server.receivedReqs[1]
-	assert.Equal(t, endAtParam+"=3.14", req.URL.Query().Encode())
+	assert.Equal(t, `3.14`, req.URL.Query().Get(endAtParam))
 
 	req = server.receivedReqs[2]
-	assert.Equal(t, endAtParam+"=%224%22", req.URL.Query().Encode())
+	assert.Equal(t, `"4"`, req.URL.Query().Get(endAtParam))
 
 	req = server.receivedReqs[3]
-	assert.Equal(t, endAtParam+"=%22theend%22", req.URL.Query().Encode())
+	assert.Equal(t, `"theend"`, req.URL.Query().Get(endAtParam))
 }
 
 func TestIncludePriority(t *testing.T) {
@@ -248,6 +382,75 @@ func TestQueryMultipleParams(t *testing.T) {
 	assert.Equal(t, orderByParam+"=%22user_id%22&startAt=7", req.URL.Query().Encode())
 }
 
+func TestQuery(t *testing.T) {
+	t.Parallel()
+	var (
+		server = newTestServer("")
+		fb     = New(server.URL, nil)
+	)
+	defer server.Close()
+
+	q, err := fb.LimitToLast(3).Query(Query{
+		OrderBy:      "$key",
+		StartAt:      "7",
+		EndAt:        "z",
+		LimitToFirst: 10,
+	})
+	require.NoError(t, err)
+	q.Value("")
+	require.Len(t, server.receivedReqs, 1)
+
+	req := server.receivedReqs[0]
+	assert.Equal(t, `endAt="z"&limitToFirst=10&orderBy="$key"&startAt="7"`, unescape(t, req.URL.Query().Encode()))
+
+	q, err = fb.Query(Query{Shallow: true})
+	require.NoError(t, err)
+	assert.Equal(t, "true", q.params.Get(shallowParam))
+
+	_, err = fb.Query(Query{OrderBy: "$key", LimitToFirst: 1, LimitToLast: 1})
+	assert.EqualError(t, err, "limitToFirst and limitToLast cannot be used together")
+
+	_, err = fb.Query(Query{StartAt: 1})
+	assert.EqualError(t, err, "startAt requires orderBy")
+}
+
+func unescape(t *testing.T, s string) string {
+	s, err := _url.QueryUnescape(s)
+	require.NoError(t, err)
+	return s
+}
+
+func TestQueryValidation(t *testing.T) {
+	t.Parallel()
+	var (
+		server = newTestServer("")
+		fb     = New(server.URL, nil)
+	)
+	defer server.Close()
+
+	for _, tt := range []struct {
+		ref *Firebase
+		err string
+	}{
+		{fb.StartAt("a"), "startAt requires orderBy"},
+		{fb.EndAt("a"), "endAt requires orderBy"},
+		{fb.EqualTo("a"), "equalTo requires orderBy"},
+		{fb.LimitToFirst(1), "limitToFirst requires orderBy"},
+		{fb.LimitToLast(1), "limitToLast requires orderBy"},
+		{fb.OrderByKey().LimitToFirst(1).LimitToLast(1), "limitToFirst and limitToLast cannot be used together"},
+		{fb.OrderByKey().EqualTo("a").StartAt("a"), "equalTo cannot be used with startAt or endAt"},
+		{fb.OrderByKey().EqualTo("a").EndAt("a"), "equalTo cannot be used with startAt or endAt"},
+		{fb.OrderByKey().ShallowRef(), "shallow cannot be used with orderBy"},
+	} {
+		assert.EqualError(t, tt.ref.Value(nil), tt.err)
+	}
+	assert.Len(t, server.receivedReqs, 0)
+
+	// writes ignore query parameters
+	require.NoError(t, fb.StartAt("a").Set("foo"))
+	assert.Len(t, server.receivedReqs, 1)
+}
+
 func TestEscapeString(t *testing.T) {
 	t.Parallel()
 
@@ -264,6 +467,66 @@ func TestEscapeString(t *testing.T) {
 	}
 }
 
+func TestStartAtKey(t *testing.T) {
+	t.Parallel()
+	var (
+		server = newTestServer(`{
+			"alice": {"age": 30},
+			"bob": {"age": 30},
+			"carol": {"age": 30},
+			"dave": {"age": 31},
+			"erin": {"age": 32}
+		}`)
+		fb = New(server.URL, nil).OrderBy("age")
+	)
+	defer server.Close()
+
+	var users map[string]interface{}
+	require.NoError(t, fb.StartAtKey(30, "bob").Value(&users))
+	require.Len(t, server.receivedReqs, 1)
+	assert.Equal(t, `30`, server.receivedReqs[0].URL.Query().Get(startAtParam))
+	assert.Len(t, users, 4)
+	assert.NotContains(t, users, "alice")
+
+	users = nil
+	require.NoError(t, fb.EndAtKey(30, "bob").Value(&users))
+	assert.Equal(t, `30`, server.receivedReqs[1].URL.Query().Get(endAtParam))
+	assert.Len(t, users, 4)
+	assert.NotContains(t, users, "carol")
+
+	// a new bound without key clears the key
+	users = nil
+	require.NoError(t, fb.StartAtKey(30, "bob").StartAtValue(30).Value(&users))
+	assert.Len(t, users, 5)
+
+	// keys are unique when ordering by key
+	users = nil
+	require.NoError(t, New(server.URL, nil).OrderByKey().StartAtKey("bob", "bob").Value(&users))
+	assert.Len(t, users, 5)
+
+	err := New(server.URL, nil).OrderByPriority().StartAtKey(1, "bob").Value(&users)
+	assert.EqualError(t, err, "startAt and endAt keys cannot be used when ordering by priority")
+}
+
+func TestStartAtKeyValue(t *testing.T) {
+	t.Parallel()
+	var (
+		server = newTestServer(`{"a": "x", "b": "x", "c": "y", "d": {"nested": "x"}}`)
+		fb     = New(server.URL, nil)
+	)
+	defer server.Close()
+
+	var values map[string]interface{}
+	require.NoError(t, fb.OrderByValue().StartAtKey("x", "b").Value(&values))
+	assert.Equal(t, []string{"b", "c", "d"}, sortedKeys(values))
+
+	values = nil
+	require.NoError(t, fb.OrderBy("nested").EndAtKey("x", "c").Value(&values))
+	assert.Equal(t, []string{"a", "b", "c"}, sortedKeys(values))
+}
+
+type label string
+
 func TestEscapeParameter(t *testing.T) {
 	t.Parallel()
 
@@ -277,8 +540,33 @@ func TestEscapeParameter(t *testing.T) {
 		{true, `true`},
 		{"false", `"false"`},
 		{3.14, `3.14`},
+		{int64(1) << 53, `9007199254740992`},
+		{"a\u0001<b>", `"a\u0001<b>"`},
+		{"caf\u00e9", `"café"`},
+		{label("foo"), `"foo"`},
+		{json.Number("7"), `7`},
+		{nil, `null`},
 	}
 	for _, testCase := range testCases {
 		assert.Equal(t, testCase.expected, escapeParameter(testCase.value))
 	}
 }
+
+func TestOrderBySpecialValues(t *testing.T) {
+	t.Parallel()
+	var (
+		server = newTestServer("")
+		fb     = New(server.URL, nil)
+	)
+	defer server.Close()
+
+	fb.OrderByKey().Value("")
+	fb.OrderByValue().Value("")
+	fb.OrderByPriority().StartAtValue(2).Value("")
+	require.Len(t, server.receivedReqs, 3)
+
+	assert.Equal(t, `"$key"`, server.receivedReqs[0].URL.Query().Get(orderByParam))
+	assert.Equal(t, `"$value"`, server.receivedReqs[1].URL.Query().Get(orderByParam))
+	assert.Equal(t, `"$priority"`, server.receivedReqs[2].URL.Query().Get(orderByParam))
+	assert.Equal(t, "2", server.receivedReqs[2].URL.Query().Get(startAtParam))
+}
@@ -0,0 +1,289 @@
+package firego
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// ErrQueued is returned, wrapping the network error that prevented it,
+// by a write that was added to the offline queue, see SetOfflineQueue.
+var ErrQueued = errors.New("write queued")
+
+// FlushError is returned by a write made while writes were queued when
+// Firebase rejected queued writes sent ahead of it, and by FlushQueue when
+// the flush stopped after such writes. Err is the error of the write, nil
+// if it succeeded, or the error that stopped the flush.
+type FlushError struct {
+	// Rejected is the error of the first rejected queued write.
+	Rejected error
+	Err      error
+}
+
+func (e *FlushError) Error() string {
+	if e.Err == nil {
+		return e.Rejected.Error()
+	}
+	return fmt.Sprintf("%s; %s", e.Err, e.Rejected)
+}
+
+// Unwrap returns the error of the flush or of the write.
+func (e *FlushError) Unwrap() error {
+	return e.Err
+}
+
+// QueuedWrite is a write waiting in the offline queue.
+type QueuedWrite struct {
+	// Method is the HTTP method of the write: PUT, PATCH, POST or DELETE.
+	Method string `json:"method"`
+	// Path is the escaped path of the written location, relative to
+	// the root of the database.
+	Path string `json:"path"`
+	// Body is the JSON document sent with the write, if any.
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// QueueStore holds the writes of the offline queue, SetOfflineQueue makes
+// sure its methods are not called concurrently. The writes can be kept in
+// memory, see NewMemoryQueue, or on disk to survive restarts.
+type QueueStore interface {
+	// Append adds the given write at the end of the queue.
+	Append(w QueuedWrite) error
+	// Writes returns the writes of the queue, oldest first.
+	Writes() ([]QueuedWrite, error)
+	// Remove removes the given number of writes from the start of the queue.
+	Remove(n int) error
+}
+
+// NewMemoryQueue creates a QueueStore holding the writes in memory.
+func NewMemoryQueue() QueueStore {
+	return &memoryQueue{}
+}
+
+type memoryQueue struct {
+	writes []QueuedWrite
+}
+
+func (q *memoryQueue) Append(w QueuedWrite) error {
+	q.writes = append(q.writes, w)
+	return nil
+}
+
+func (q *memoryQueue) Writes() ([]QueuedWrite, error) {
+	return append([]QueuedWrite(nil), q.writes...), nil
+}
+
+func (q *memoryQueue) Remove(n int) error {
+	if n > len(q.writes) {
+		n = len(q.writes)
+	}
+	q.writes = q.writes[n:]
+	return nil
+}
+
+// writeQueue guards the store of an offline queue.
+type writeQueue struct {
+	mtx   sync.Mutex
+	store QueueStore
+	// owner is the reference the queue was set on, whose
+	// credentials the queued writes are sent with
+	owner *Firebase
+}
+
+// credentials are what a reference authenticates to Firebase with.
+type credentials struct {
+	auth         string
+	authOverride string
+	sharedAuth   *Auth
+	tokenSource  TokenSource
+	authMode     AuthMode
+}
+
+func (fb *Firebase) getCredentials() credentials {
+	fb.paramsMtx.RLock()
+	defer fb.paramsMtx.RUnlock()
+	return credentials{
+		auth:         fb.params.Get(authParam),
+		authOverride: fb.params.Get(authOverrideParam),
+		sharedAuth:   fb.sharedAuth,
+		tokenSource:  fb.tokenSource,
+		authMode:     fb.authMode,
+	}
+}
+
+func (c credentials) equal(other credentials) bool {
+	return c.auth == other.auth &&
+		c.authOverride == other.authOverride &&
+		c.sharedAuth == other.sharedAuth &&
+		sameTokenSource(c.tokenSource, other.tokenSource) &&
+		c.authMode == other.authMode
+}
+
+// sameTokenSource reports whether a and b are the same token source,
+// including the sources of uncomparable types such as TokenSourceFunc.
+func sameTokenSource(a, b TokenSource) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Type() != vb.Type() {
+		return false
+	}
+	if va.Type().Comparable() {
+		return a == b
+	}
+	return va.Kind() == reflect.Func && va.Pointer() == vb.Pointer()
+}
+
+// flushKey marks the context of the writes made by the queue itself.
+type flushKey struct{}
+
+// SetOfflineQueue makes the writes of the reference, and of the references
+// derived from it afterwards which share the queue, that fail because of a
+// network error, such as Set, Update, Push and Remove, be added to the given
+// store to be sent again later, and return an error wrapping ErrQueued.
+// Timeouts, see ErrTimeout, count as network errors, so a write whose
+// response did not come in time is sent again even if it was made.
+// While writes are queued, new writes first send the queued ones, so that
+// they are made in order, and are queued as well if that fails. FlushQueue
+// sends the queued writes once the network is back. Writes sharing a queue
+// are made one at a time.
+//
+// The queued writes are sent with the credentials of the reference the queue
+// is set on, so the writes of references with other credentials, such as
+// those created by WithAuth, are not queued. Conditional writes, such as
+// SetIfMatch and Transaction, writes made with options, such as the silent
+// ones, and the writes of the rules are never queued either. Writes pushed
+// while offline get their key once they are sent. A nil store stops queuing
+// writes, the writes that are still queued are left in the store.
+func (fb *Firebase) SetOfflineQueue(store QueueStore) {
+	var queue *writeQueue
+	if store != nil {
+		queue = &writeQueue{store: store, owner: fb}
+	}
+
+	fb.paramsMtx.Lock()
+	fb.queue = queue
+	fb.paramsMtx.Unlock()
+}
+
+func (fb *Firebase) getQueue() *writeQueue {
+	fb.paramsMtx.RLock()
+	defer fb.paramsMtx.RUnlock()
+	return fb.queue
+}
+
+// QueueLen returns the number of writes waiting in the offline queue.
+// It returns 0 when there is no queue or its writes cannot be read.
+func (fb *Firebase) QueueLen() int {
+	queue := fb.getQueue()
+	if queue == nil {
+		return 0
+	}
+	queue.mtx.Lock()
+	defer queue.mtx.Unlock()
+	writes, err := queue.store.Writes()
+	if err != nil {
+		return 0
+	}
+	return len(writes)
+}
+
+// FlushQueue sends the writes of the offline queue in order, with the
+// credentials and configuration of the reference the queue is set on,
+// removing them from the queue once sent. It stops at the first write that
+// fails because of a network error, which stays queued, and returns the
+// error, in a FlushError if Firebase rejected writes sent before. The writes
+// that Firebase rejects are removed from the queue, the error of the first
+// one being returned once the others are sent.
+func (fb *Firebase) FlushQueue() error {
+	queue := fb.getQueue()
+	if queue == nil {
+		return nil
+	}
+	queue.mtx.Lock()
+	defer queue.mtx.Unlock()
+	rejected, err := queue.flush()
+	if err == nil {
+		return rejected
+	}
+	return flushError(rejected, err)
+}
+
+// flush sends the queued writes, returning the error of the first rejected
+// one and the error that stopped the flush, if any.
+func (queue *writeQueue) flush() (rejected, err error) {
+	writes, err := queue.store.Writes()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.WithValue(context.Background(), flushKey{}, true)
+	root := queue.owner.Root()
+	for i, w := range writes {
+		ref := root.copy()
+		if w.Path != "" {
+			ref.url += "/" + w.Path
+		}
+		_, _, err := ref.doRequest(ctx, w.Method, w.Body)
+		if isOffline(err) {
+			if removeErr := queue.store.Remove(i); removeErr != nil {
+				return rejected, removeErr
+			}
+			return rejected, err
+		}
+		if err != nil && rejected == nil {
+			rejected = fmt.Errorf("queued %s of %q failed: %w", w.Method, "/"+w.Path, err)
+		}
+	}
+	return rejected, queue.store.Remove(len(writes))
+}
+
+// flushError returns err along with the rejected error of a flush, if any.
+func flushError(rejected, err error) error {
+	if rejected == nil {
+		return err
+	}
+	return &FlushError{Rejected: rejected, Err: err}
+}
+
+// queueWrite makes the given write through the offline queue of the
+// reference, reporting whether it did; writes that cannot be queued,
+// or made when there is no queue, are left to the caller.
+func (fb *Firebase) queueWrite(ctx context.Context, method string, body []byte, send func() error) (bool, error) {
+	queue := fb.getQueue()
+	if queue == nil || method == "GET" || ctx.Value(flushKey{}) != nil {
+		return false, nil
+	}
+	if queue.owner != fb && !fb.getCredentials().equal(queue.owner.getCredentials()) {
+		// the write would be replayed on behalf of another user
+		return false, nil
+	}
+	queue.mtx.Lock()
+	defer queue.mtx.Unlock()
+
+	// the queued writes are made first to keep the writes in order
+	rejected, err := queue.flush()
+	if !isOffline(err) {
+		if err = send(); !isOffline(err) {
+			return true, flushError(rejected, err)
+		}
+	}
+
+	_, path := splitURL(fb.url)
+	w := QueuedWrite{Method: method, Path: path, Body: body}
+	if queueErr := queue.store.Append(w); queueErr != nil {
+		return true, flushError(rejected, fmt.Errorf("failed to queue the write: %s: %w", queueErr, err))
+	}
+	return true, flushError(rejected, fmt.Errorf("%w: %s", ErrQueued, err))
+}
+
+// isOffline reports whether err prevented a write from reaching Firebase,
+// such as a refused connection or a host that does not answer in time.
+func isOffline(err error) bool {
+	var timeout ErrTimeout
+	return errors.As(err, &timeout) || isConnectionError(err)
+}
@@ -0,0 +1,193 @@
+package firego
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOfflineQueue(t *testing.T) {
+	t.Parallel()
+	var (
+		mtx    sync.Mutex
+		writes []string
+	)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		mtx.Lock()
+		writes = append(writes, req.Method+" "+req.URL.Path+" "+string(body))
+		mtx.Unlock()
+		if req.URL.Path == "/rejected/.json" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"Invalid data"}`))
+			return
+		}
+		w.Write([]byte(`{"name":"key"}`))
+	}))
+	defer server.Close()
+
+	// nothing listens until the network is back
+	addr := server.Listener.Addr().String()
+	server.Listener.Close()
+
+	fb := New("http://"+addr, nil)
+	fb.SetOfflineQueue(NewMemoryQueue())
+	users := fb.Child("users")
+
+	err := users.Child("alice").Set(map[string]int{"age": 30})
+	assert.True(t, errors.Is(err, ErrQueued), "%v", err)
+	err = users.Update(map[string]interface{}{"bob/age": 40})
+	assert.True(t, errors.Is(err, ErrQueued), "%v", err)
+	_, err = fb.Child("rejected").Push(true)
+	assert.True(t, errors.Is(err, ErrQueued), "%v", err)
+	err = users.Child("carol").Remove()
+	assert.True(t, errors.Is(err, ErrQueued), "%v", err)
+	assert.Equal(t, 4, fb.QueueLen())
+	assert.Error(t, fb.FlushQueue())
+	assert.Equal(t, 4, fb.QueueLen())
+
+	// reads are not queued
+	assert.False(t, errors.Is(fb.Value(new(interface{})), ErrQueued))
+
+	listener, err := net.Listen("tcp", addr)
+	require.NoError(t, err)
+	server.Listener = listener
+	server.Start()
+
+	err = fb.FlushQueue()
+	require.Error(t, err)
+	assert.True(t, hasStatusCode(err, http.StatusBadRequest), "%v", err)
+	assert.Contains(t, err.Error(), `queued POST of "/rejected"`)
+	assert.Zero(t, fb.QueueLen())
+	assert.Equal(t, []string{
+		`PUT /users/alice/.json {"age":30}`,
+		`PATCH /users/.json {"bob/age":40}`,
+		`POST /rejected/.json true`,
+		`DELETE /users/carol/.json `,
+	}, writes)
+	assert.NoError(t, fb.FlushQueue())
+}
+
+func TestOfflineQueue_Order(t *testing.T) {
+	t.Parallel()
+	var writes []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		writes = append(writes, string(body))
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	store := NewMemoryQueue()
+	require.NoError(t, store.Append(QueuedWrite{Method: "PUT", Path: "counter", Body: []byte("1")}))
+
+	fb := New(server.URL, nil)
+	fb.SetOfflineQueue(store)
+	require.NoError(t, fb.Child("counter").Set(2))
+	assert.Equal(t, []string{"1", "2"}, writes)
+	assert.Zero(t, fb.QueueLen())
+
+	fb.SetOfflineQueue(nil)
+	assert.Zero(t, fb.QueueLen())
+	assert.NoError(t, fb.FlushQueue())
+}
+
+func TestOfflineQueue_Credentials(t *testing.T) {
+	t.Parallel()
+	var tokens []string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		tokens = append(tokens, req.URL.Path+" "+req.URL.Query().Get(authParam))
+		w.Write([]byte("true"))
+	}))
+	defer server.Close()
+
+	addr := server.Listener.Addr().String()
+	server.Listener.Close()
+
+	fb := New("http://"+addr, nil)
+	fb.Auth("alice-token")
+	fb.SetOfflineQueue(NewMemoryQueue())
+
+	alice := fb.Child("alice").WithAuth("alice-token")
+	err := alice.Set(true)
+	assert.True(t, errors.Is(err, ErrQueued), "%v", err)
+
+	// bob's write is not replayed with alice's credentials
+	bob := fb.Child("bob").WithAuth("bob-token")
+	err = bob.Set(true)
+	require.Error(t, err)
+	assert.False(t, errors.Is(err, ErrQueued), "%v", err)
+
+	err = fb.SetRules([]byte(`{"rules":{}}`))
+	require.Error(t, err)
+	assert.False(t, errors.Is(err, ErrQueued), "%v", err)
+	assert.Equal(t, 1, fb.QueueLen())
+
+	listener, err := net.Listen("tcp", addr)
+	require.NoError(t, err)
+	server.Listener = listener
+	server.Start()
+
+	// the queue is flushed with the credentials of its owner
+	require.NoError(t, bob.FlushQueue())
+	assert.Equal(t, []string{"/alice/.json alice-token"}, tokens)
+}
+
+func TestOfflineQueue_Rejected(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/rejected/.json" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"Invalid data"}`))
+			return
+		}
+		w.Write([]byte("true"))
+	}))
+	defer server.Close()
+
+	store := NewMemoryQueue()
+	require.NoError(t, store.Append(QueuedWrite{Method: "PUT", Path: "rejected", Body: []byte("1")}))
+
+	fb := New(server.URL, nil)
+	fb.SetOfflineQueue(store)
+	err := fb.Child("accepted").Set(true)
+	var flushErr *FlushError
+	require.True(t, errors.As(err, &flushErr), "%v", err)
+	assert.NoError(t, flushErr.Err)
+	assert.True(t, hasStatusCode(flushErr.Rejected, http.StatusBadRequest), "%v", err)
+	assert.Contains(t, err.Error(), `queued PUT of "/rejected"`)
+	assert.Zero(t, fb.QueueLen())
+}
+
+func TestOfflineQueue_Timeout(t *testing.T) {
+	t.Parallel()
+	// the connections are accepted but never answered
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	fb := New("http://"+listener.Addr().String(), nil)
+	fb.SetTimeout(50 * time.Millisecond)
+	fb.SetOfflineQueue(NewMemoryQueue())
+
+	err = fb.Child("alice").Set(true)
+	assert.True(t, errors.Is(err, ErrQueued), "%v", err)
+	assert.Equal(t, 1, fb.QueueLen())
+}
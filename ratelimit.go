@@ -0,0 +1,44 @@
+package firego
+
+import (
+	"context"
+
+	"golang.org/x/time/rate"
+)
+
+// SetRateLimit limits the requests made by the reference, and by the
+// references derived from it afterwards which share the same budget, to
+// requestsPerSecond with bursts of up to burst requests. Requests wait for
+// their turn, or until their context is done. Every attempt of a retried
+// request counts, while watches, which are long-lived, only count when
+// connecting. A requestsPerSecond of zero or less removes the limit.
+func (fb *Firebase) SetRateLimit(requestsPerSecond float64, burst int) {
+	var limiter *rate.Limiter
+	if requestsPerSecond > 0 {
+		if burst < 1 {
+			burst = 1
+		}
+		limiter = rate.NewLimiter(rate.Limit(requestsPerSecond), burst)
+	}
+
+	fb.paramsMtx.Lock()
+	fb.limiter = limiter
+	fb.paramsMtx.Unlock()
+}
+
+// waitRateLimit blocks until the rate limit of the reference allows a request.
+func (fb *Firebase) waitRateLimit(ctx context.Context) error {
+	fb.paramsMtx.RLock()
+	limiter := fb.limiter
+	fb.paramsMtx.RUnlock()
+	if limiter == nil {
+		return nil
+	}
+	if err := limiter.Wait(ctx); err != nil {
+		if ctx.Err() != nil {
+			return contextError(ctx)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package firego
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetRateLimit(t *testing.T) {
+	t.Parallel()
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		fmt.Fprint(w, "null")
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	fb.SetRateLimit(20, 2)
+	child := fb.Child("child")
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func(ref *Firebase) {
+			defer wg.Done()
+			assert.NoError(t, ref.Value(new(interface{})))
+		}([]*Firebase{fb, child}[i%2])
+	}
+	wg.Wait()
+	// the burst goes through at once, the other requests every 50ms
+	assert.True(t, time.Since(start) >= 150*time.Millisecond, "elapsed: %s", time.Since(start))
+	assert.Equal(t, int32(6), atomic.LoadInt32(&requests))
+
+	fb.SetRateLimit(0, 0)
+	start = time.Now()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, fb.Value(new(interface{})))
+	}
+	assert.True(t, time.Since(start) < 150*time.Millisecond, "elapsed: %s", time.Since(start))
+}
+
+func TestSetRateLimit_Context(t *testing.T) {
+	t.Parallel()
+	server := newTestServer("null")
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	fb.SetRateLimit(0.1, 1)
+	require.NoError(t, fb.Value(new(interface{})))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := fb.ValueWithContext(ctx, new(interface{}))
+	assert.Error(t, err)
+	assert.Len(t, server.receivedReqs, 1)
+}
@@ -0,0 +1,134 @@
+package firego
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+)
+
+// errInvalidJSON is returned by the raw writes when given malformed data.
+var errInvalidJSON = errors.New("data is not valid JSON")
+
+// SetRawJSON sets the value of the Firebase reference to the given JSON
+// document, which is sent unchanged instead of being marshaled.
+func (fb *Firebase) SetRawJSON(data []byte) error {
+	if !json.Valid(data) {
+		return errInvalidJSON
+	}
+	if err := validateKeys(data, false); err != nil {
+		return err
+	}
+	_, _, err := fb.doRequest(context.Background(), "PUT", data)
+	return err
+}
+
+// UpdateRawJSON updates the specific child with the given JSON object,
+// which is sent unchanged instead of being marshaled.
+func (fb *Firebase) UpdateRawJSON(data []byte) error {
+	if !json.Valid(data) {
+		return errInvalidJSON
+	}
+	if err := validateKeys(data, true); err != nil {
+		return err
+	}
+	_, _, err := fb.doRequest(context.Background(), "PATCH", data)
+	return err
+}
+
+// PushRawJSON adds the given JSON document, sent unchanged instead of being
+// marshaled, to an auto-generated child location and returns a reference to it.
+func (fb *Firebase) PushRawJSON(data []byte) (*Firebase, error) {
+	if !json.Valid(data) {
+		return nil, errInvalidJSON
+	}
+	if err := validateKeys(data, false); err != nil {
+		return nil, err
+	}
+	_, newRef, err := fb.pushRaw(context.Background(), data)
+	return newRef, err
+}
+
+// GetRaw returns the value of the Firebase reference as the JSON document
+// sent by Firebase, without unmarshaling it.
+func (fb *Firebase) GetRaw() ([]byte, error) {
+	_, body, err := fb.doRequest(context.Background(), "GET", nil)
+	return body, err
+}
+
+// SetIfChanged sets the value of the Firebase reference unless it already
+// holds the same data, in which case nothing is written and no event is
+// sent to the watchers of the location. It reports whether a write was
+// made. The current value is read first and compared as JSON, ignoring
+// key order and formatting as well as null and empty children, which
+// Firebase does not store. The read and the write are not atomic, see
+// SetIfMatch to make sure the data did not change in between.
+func (fb *Firebase) SetIfChanged(v interface{}) (bool, error) {
+	data, err := fb.marshalValue(v)
+	if err != nil {
+		return false, err
+	}
+	current, err := fb.GetRaw()
+	if err != nil {
+		return false, err
+	}
+
+	if equal, err := equalJSON(current, data); err != nil || equal {
+		return false, err
+	}
+	_, _, err = fb.doRequest(context.Background(), "PUT", data)
+	return err == nil, err
+}
+
+// equalJSON reports whether the given JSON documents hold the same data.
+func equalJSON(a, b []byte) (bool, error) {
+	va, err := decodeExact(a)
+	if err != nil {
+		return false, err
+	}
+	vb, err := decodeExact(b)
+	if err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(normalize(va), normalize(vb)), nil
+}
+
+// exactNumber is the canonical form of a JSON number, see decodeExact.
+type exactNumber string
+
+// decodeExact decodes the given JSON document without rounding its integers
+// to float64, numbers being turned into their canonical form so that the
+// ones written differently, such as 1 and 1.0, are still equal.
+func decodeExact(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return exactNumbers(v), nil
+}
+
+func exactNumbers(value interface{}) interface{} {
+	switch value := value.(type) {
+	case json.Number:
+		if n, err := strconv.ParseInt(string(value), 10, 64); err == nil {
+			return exactNumber(strconv.FormatInt(n, 10))
+		}
+		if f, err := value.Float64(); err == nil {
+			return exactNumber(strconv.FormatFloat(f, 'g', -1, 64))
+		}
+		return exactNumber(value)
+	case map[string]interface{}:
+		for key, child := range value {
+			value[key] = exactNumbers(child)
+		}
+	case []interface{}:
+		for i, child := range value {
+			value[i] = exactNumbers(child)
+		}
+	}
+	return value
+}
@@ -0,0 +1,114 @@
+package firego
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRawJSON(t *testing.T) {
+	t.Parallel()
+	var (
+		data     = []byte(`{ "amount": 12345678901234567890 }`)
+		received []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		received = append(received, req.Method+" "+string(body))
+		w.Write([]byte(`{"name":"-key"}`))
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	require.NoError(t, fb.SetRawJSON(data))
+	require.NoError(t, fb.UpdateRawJSON(data))
+	ref, err := fb.PushRawJSON(data)
+	require.NoError(t, err)
+	assert.Equal(t, server.URL+"/-key", ref.URL())
+
+	assert.Equal(t, []string{
+		"PUT " + string(data),
+		"PATCH " + string(data),
+		"POST " + string(data),
+	}, received)
+
+	invalid := []byte(`{"foo":`)
+	assert.Error(t, fb.SetRawJSON(invalid))
+	assert.Error(t, fb.UpdateRawJSON(invalid))
+	_, err = fb.PushRawJSON(invalid)
+	assert.Error(t, err)
+	assert.Len(t, received, 3)
+}
+
+func TestGetRaw(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"amount":12345678901234567890}`))
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	data, err := fb.GetRaw()
+	require.NoError(t, err)
+	assert.Equal(t, `{"amount":12345678901234567890}`, string(data))
+}
+
+func TestSetIfChanged(t *testing.T) {
+	t.Parallel()
+	var puts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "PUT" {
+			puts++
+			b, _ := ioutil.ReadAll(req.Body)
+			w.Write(b)
+			return
+		}
+		w.Write([]byte(`{"b":{"c":2,"d":null},"a":1,"e":{}}`))
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	changed, err := fb.SetIfChanged(map[string]interface{}{
+		"a": 1,
+		"b": map[string]int{"c": 2},
+	})
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, 0, puts)
+
+	changed, err = fb.SetIfChanged(map[string]interface{}{"a": 2})
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, 1, puts)
+}
+
+func TestSetIfChanged_LargeInteger(t *testing.T) {
+	t.Parallel()
+	var puts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "PUT" {
+			puts++
+			b, _ := ioutil.ReadAll(req.Body)
+			w.Write(b)
+			return
+		}
+		w.Write([]byte(`{"id":9007199254740992,"ratio":1.0}`))
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	changed, err := fb.SetIfChanged(map[string]interface{}{"id": int64(9007199254740992), "ratio": 1})
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, 0, puts)
+
+	// equal as float64, but not the same integer
+	changed, err = fb.SetIfChanged(map[string]interface{}{"id": int64(9007199254740993), "ratio": 1})
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, 1, puts)
+}
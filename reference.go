@@ -0,0 +1,19 @@
+package firego
+
+// Reference is the set of basic operations on a Firebase location.
+// *Firebase implements it, code that only needs these operations can
+// accept a Reference so that tests can provide a fake implementation.
+//
+// Child, Ref and Push return the concrete *Firebase to keep the methods
+// of *Firebase unchanged, fakes can return references created with New.
+type Reference interface {
+	Get(v interface{}) error
+	Set(v interface{}) error
+	Update(v interface{}) error
+	Push(v interface{}) (*Firebase, error)
+	Remove() error
+	Child(child string) *Firebase
+	Ref(path string) (*Firebase, error)
+}
+
+var _ Reference = (*Firebase)(nil)
@@ -0,0 +1,39 @@
+package firego
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const rulesPath = ".settings/rules"
+
+// GetRules returns the security rules of the database the reference
+// belongs to, regardless of the path of the reference.
+// Reading the rules requires a database secret or an owner access token.
+//
+// Reference https://firebase.google.com/docs/reference/rest/database#section-get-rules
+func (fb *Firebase) GetRules() ([]byte, error) {
+	_, body, err := fb.specialRef(rulesPath).doRequest(context.Background(), "GET", nil)
+	return body, err
+}
+
+// SetRules replaces the security rules of the database the reference
+// belongs to, regardless of the path of the reference. The rules must be
+// valid JSON, comments are not supported.
+// Writing the rules requires a database secret or an owner access token.
+//
+// Reference https://firebase.google.com/docs/reference/rest/database#section-put-rules
+func (fb *Firebase) SetRules(rules []byte) error {
+	if !json.Valid(rules) {
+		return errors.New("rules are not valid JSON")
+	}
+
+	_, _, err := fb.specialRef(rulesPath).doRequest(context.Background(), "PUT", rules)
+	if fbErr, ok := err.(*FirebaseError); ok && fbErr.Message != "" {
+		// Firebase explains why the rules were rejected
+		return fmt.Errorf("failed to set rules. %w", fbErr)
+	}
+	return err
+}
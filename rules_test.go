@@ -0,0 +1,105 @@
+package firego
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRules(t *testing.T) {
+	t.Parallel()
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		path = req.URL.Path
+		w.Write([]byte(`{"rules":{".read":true}}`))
+	}))
+	defer server.Close()
+
+	fb := New(server.URL+"/some/path", nil)
+	rules, err := fb.GetRules()
+	require.NoError(t, err)
+	assert.Equal(t, "/.settings/rules/.json", path)
+	assert.JSONEq(t, `{"rules":{".read":true}}`, string(rules))
+}
+
+func TestGetRules_Query(t *testing.T) {
+	t.Parallel()
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		query = req.URL.RawQuery
+		w.Write([]byte(`{"rules":{}}`))
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	fb.Auth("token")
+	ref := fb.OrderBy("age").StartAtKey(30, "bob").LimitToFirst(10)
+	rules, err := ref.GetRules()
+	require.NoError(t, err)
+	assert.Equal(t, "auth=token", query)
+	assert.JSONEq(t, `{"rules":{}}`, string(rules))
+}
+
+func TestSetRules(t *testing.T) {
+	t.Parallel()
+	var (
+		path string
+		body []byte
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		path = req.URL.Path
+		body, _ = ioutil.ReadAll(req.Body)
+		if req.URL.Query().Get(authParam) != authToken {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"Line 1: Expected '.read' to be a boolean"}`))
+			return
+		}
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer server.Close()
+
+	fb := New(server.URL+"/some/path", nil)
+	fb.Auth(authToken)
+
+	rules := []byte(`{"rules":{".read":true}}`)
+	require.NoError(t, fb.SetRules(rules))
+	assert.Equal(t, "/.settings/rules/.json", path)
+	assert.Equal(t, rules, body)
+
+	fb.Unauth()
+	err := fb.SetRules(rules)
+	require.Error(t, err)
+	assert.Equal(t, "failed to set rules. Line 1: Expected '.read' to be a boolean", err.Error())
+	assert.True(t, hasStatusCode(err, http.StatusBadRequest), "%v", err)
+}
+
+func TestSetRulesUnauthorized(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"Permission denied"}`))
+	}))
+	defer server.Close()
+
+	err := New(server.URL, nil).SetRules([]byte(`{"rules":{}}`))
+	require.Error(t, err)
+	assert.True(t, IsUnauthorized(err), "%v", err)
+	assert.Equal(t, "failed to set rules. Permission denied", err.Error())
+}
+
+func TestSetRulesInvalidJSON(t *testing.T) {
+	t.Parallel()
+	var (
+		server = newTestServer("")
+		fb     = New(server.URL, nil)
+	)
+	defer server.Close()
+
+	err := fb.SetRules([]byte(`{"rules": // comment`))
+	assert.Error(t, err)
+	assert.Len(t, server.receivedReqs, 0)
+}
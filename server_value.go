@@ -0,0 +1,37 @@
+package firego
+
+import "encoding/json"
+
+// ServerValue is a placeholder value that Firebase replaces with a
+// value computed on the server when the write is applied.
+//
+// Reference https://firebase.google.com/docs/reference/rest/database#section-server-values
+type ServerValue struct {
+	value interface{}
+}
+
+// ServerTimestamp is replaced by Firebase with the current time, in
+// milliseconds since the Unix epoch, when it is written. It may be used
+// anywhere in the value given to Set, Update or Push.
+//
+//	fb.Set(map[string]interface{}{"createdAt": firego.ServerTimestamp})
+var ServerTimestamp = ServerValue{value: "timestamp"}
+
+// MarshalJSON implements json.Marshaler.
+func (s ServerValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{".sv": s.value})
+}
+
+// ServerIncrement returns a ServerValue that Firebase replaces with the sum
+// of the number stored at its location, 0 if there is none, and delta. The
+// increment is applied atomically by Firebase without the value being read,
+// it may be used anywhere in the value given to Set or Update, including
+// in the paths of a multi-path update:
+//
+//	fb.UpdateChildren(map[string]interface{}{
+//		"posts/p1/likes":    firego.ServerIncrement(1),
+//		"users/alice/likes": firego.ServerIncrement(1),
+//	})
+func ServerIncrement(delta float64) ServerValue {
+	return ServerValue{value: map[string]float64{"increment": delta}}
+}
@@ -0,0 +1,93 @@
+package firego
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerTimestamp(t *testing.T) {
+	t.Parallel()
+	type nested struct {
+		CreatedAt ServerValue `json:"createdAt"`
+		UpdatedAt interface{} `json:"updatedAt"`
+	}
+
+	for _, test := range []struct {
+		name     string
+		value    interface{}
+		expected string
+	}{
+		{
+			name:     "top level",
+			value:    ServerTimestamp,
+			expected: `{".sv":"timestamp"}`,
+		},
+		{
+			name:     "map",
+			value:    map[string]interface{}{"createdAt": ServerTimestamp},
+			expected: `{"createdAt":{".sv":"timestamp"}}`,
+		},
+		{
+			name:     "struct",
+			value:    nested{CreatedAt: ServerTimestamp, UpdatedAt: ServerTimestamp},
+			expected: `{"createdAt":{".sv":"timestamp"},"updatedAt":{".sv":"timestamp"}}`,
+		},
+		{
+			name:     "pointer",
+			value:    &ServerTimestamp,
+			expected: `{".sv":"timestamp"}`,
+		},
+		{
+			name:     "increment",
+			value:    map[string]interface{}{"likes": ServerIncrement(2), "score": ServerIncrement(-0.5)},
+			expected: `{"likes":{".sv":{"increment":2}},"score":{".sv":{"increment":-0.5}}}`,
+		},
+		{
+			name:     "slice",
+			value:    []interface{}{ServerTimestamp, map[string]ServerValue{"a": ServerTimestamp}},
+			expected: `[{".sv":"timestamp"},{"a":{".sv":"timestamp"}}]`,
+		},
+	} {
+		b, err := json.Marshal(test.value)
+		require.NoError(t, err, test.name)
+		assert.JSONEq(t, test.expected, string(b), test.name)
+	}
+}
+
+func TestSetServerTimestamp(t *testing.T) {
+	t.Parallel()
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ = ioutil.ReadAll(req.Body)
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	require.NoError(t, fb.Set(map[string]interface{}{"createdAt": ServerTimestamp}))
+	assert.JSONEq(t, `{"createdAt":{".sv":"timestamp"}}`, string(body))
+}
+
+func TestUpdateServerIncrement(t *testing.T) {
+	t.Parallel()
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ = ioutil.ReadAll(req.Body)
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	require.NoError(t, fb.UpdateChildren(map[string]interface{}{
+		"posts/p1/likes":    ServerIncrement(1),
+		"users/alice/likes": ServerIncrement(1),
+	}))
+	assert.JSONEq(t, `{
+		"posts/p1/likes": {".sv": {"increment": 1}},
+		"users/alice/likes": {".sv": {"increment": 1}}
+	}`, string(body))
+}
@@ -0,0 +1,138 @@
+package firego
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+)
+
+// maxErrorBodySize limits how much of an error response is read
+// by the streaming requests.
+const maxErrorBodySize = 64 << 10
+
+// Download streams the value of the Firebase reference, as JSON, to the
+// given writer without holding the whole document in memory. This is
+// meant for large subtrees, such as backups. Requests are not retried.
+func (fb *Firebase) Download(w io.Writer) error {
+	return fb.download(func(body io.Reader) error {
+		_, err := io.Copy(w, body)
+		return err
+	})
+}
+
+// StreamArray reads the value of the Firebase reference, which should be an
+// array, calling fn with every element in turn without holding the whole
+// array in memory. Arrays that Firebase returns as objects keyed by index,
+// which it does when they have gaps, are supported as well. Iteration stops
+// at the first error returned by fn, which is returned. A missing value
+// holds no element. Requests are not retried.
+func (fb *Firebase) StreamArray(fn func(index int, item json.RawMessage) error) error {
+	return fb.download(func(body io.Reader) error {
+		return streamArray(json.NewDecoder(body), fn)
+	})
+}
+
+func streamArray(dec *json.Decoder, fn func(index int, item json.RawMessage) error) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return nil
+	}
+	delim, ok := token.(json.Delim)
+	if !ok || (delim != '[' && delim != '{') {
+		return fmt.Errorf("cannot stream %v: value is not an array", token)
+	}
+
+	for index := 0; dec.More(); index++ {
+		if delim == '{' {
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			index, err = strconv.Atoi(key.(string))
+			if err != nil || index < 0 {
+				return fmt.Errorf("cannot stream key %q: value is not an array", key)
+			}
+		}
+		var item json.RawMessage
+		if err := dec.Decode(&item); err != nil {
+			return err
+		}
+		if err := fn(index, item); err != nil {
+			return err
+		}
+	}
+	_, err = dec.Token()
+	return err
+}
+
+// download reads the value of the Firebase reference with fn, which is given
+// the body of the response.
+func (fb *Firebase) download(fn func(body io.Reader) error) error {
+	var options []func(*http.Request)
+	if fb.getCompression() {
+		options = append(options, withHeader("Accept-Encoding", "gzip"))
+	}
+
+	resp, err := fb.send(context.Background(), "GET", nil, options...)
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp.Body)
+	if err := streamError(resp); err != nil {
+		return err
+	}
+
+	body, err := decompressBody(resp)
+	if err != nil {
+		return err
+	}
+	return fn(body)
+}
+
+// Upload sets the value of the Firebase reference to the JSON document
+// read from r, which is streamed to Firebase without holding it in memory.
+// Unlike with Set, the document is not validated before being sent and
+// requests are not retried. In dry-run mode, see SetDryRun, the document
+// is read in full to be reported instead.
+func (fb *Firebase) Upload(r io.Reader) error {
+	if dryRun, hooks := fb.getDryRun(); dryRun {
+		body, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		_, _, err = fb.skipRequest(context.Background(), "PUT", body, hooks, withParam(printParam, printSilentVal))
+		return err
+	}
+
+	// the client closes request bodies, r is left to the caller
+	resp, err := fb.send(context.Background(), "PUT", ioutil.NopCloser(r), withParam(printParam, printSilentVal))
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp.Body)
+	return streamError(resp)
+}
+
+// closeBody drains what is left of a response body, up to maxErrorBodySize,
+// before closing it so that the connection can be reused for other requests.
+func closeBody(body io.ReadCloser) {
+	io.CopyN(ioutil.Discard, body, maxErrorBodySize)
+	body.Close()
+}
+
+// streamError returns a *FirebaseError if the response of a
+// streaming request has a non-2xx status code.
+func streamError(resp *http.Response) error {
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	return newFirebaseError(resp.StatusCode, resp.Header, body)
+}
@@ -0,0 +1,122 @@
+package firego
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/trevor403/firego/firetest"
+)
+
+func TestUploadDownload(t *testing.T) {
+	t.Parallel()
+	server := firetest.New()
+	server.Start()
+	defer server.Close()
+
+	fb := New(server.URL, nil).Child("backup")
+	require.NoError(t, fb.Upload(strings.NewReader(`{"a":{"b":1},"c":[true,"d"]}`)))
+
+	var v map[string]interface{}
+	require.NoError(t, fb.Value(&v))
+	assert.Equal(t, map[string]interface{}{
+		"a": map[string]interface{}{"b": float64(1)},
+		"c": []interface{}{true, "d"},
+	}, v)
+
+	var buf bytes.Buffer
+	require.NoError(t, fb.Download(&buf))
+	assert.JSONEq(t, `{"a":{"b":1},"c":[true,"d"]}`, buf.String())
+}
+
+func TestDownloadError(t *testing.T) {
+	t.Parallel()
+	server := firetest.New()
+	server.Start()
+	defer server.Close()
+	server.RequireAuth(true)
+
+	var buf bytes.Buffer
+	err := New(server.URL, nil).Download(&buf)
+	assert.True(t, IsUnauthorized(err), "%v", err)
+	assert.Zero(t, buf.Len())
+}
+
+func TestErrorResponsesReuseConnections(t *testing.T) {
+	t.Parallel()
+	errorBody := strings.Repeat("x", maxErrorBodySize+maxErrorBodySize/2)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ioutil.ReadAll(req.Body)
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, errorBody)
+	}))
+	var conns int64
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&conns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	for i := 0; i < 20; i++ {
+		var v interface{}
+		assert.Error(t, fb.Value(&v))
+		assert.Error(t, fb.Set(i))
+		assert.Error(t, fb.Download(ioutil.Discard))
+		assert.Error(t, fb.Upload(strings.NewReader(`true`)))
+		assert.Error(t, fb.Watch(make(chan Event)))
+	}
+	assert.Equal(t, int64(1), atomic.LoadInt64(&conns))
+}
+
+func TestStreamArray(t *testing.T) {
+	t.Parallel()
+	for body, expected := range map[string]map[int]string{
+		`[1,{"a":"b"},"c"]`:         {0: `1`, 1: `{"a":"b"}`, 2: `"c"`},
+		`{"0":true,"2":[3],"10":4}`: {0: `true`, 2: `[3]`, 10: `4`},
+		`null`:                      {},
+	} {
+		server := newTestServer(body)
+		items := map[int]string{}
+		err := New(server.URL, nil).StreamArray(func(index int, item json.RawMessage) error {
+			items[index] = string(item)
+			return nil
+		})
+		server.Close()
+		require.NoError(t, err, body)
+		assert.Equal(t, expected, items, body)
+	}
+}
+
+func TestStreamArrayErrors(t *testing.T) {
+	t.Parallel()
+	for _, body := range []string{`"a"`, `{"a":1}`, `[1,2`} {
+		server := newTestServer(body)
+		err := New(server.URL, nil).StreamArray(func(int, json.RawMessage) error { return nil })
+		server.Close()
+		assert.Error(t, err, body)
+	}
+
+	server := newTestServer(`[1,2,3]`)
+	defer server.Close()
+	stop := errors.New("stop")
+	var calls int
+	err := New(server.URL, nil).StreamArray(func(int, json.RawMessage) error {
+		calls++
+		return stop
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,48 @@
+package firego
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Timestamp is a time.Time encoded in JSON as the number of milliseconds
+// since the Unix epoch, which is how Firebase stores ServerTimestamp.
+// It can be used as the type of timestamp fields so they are decoded
+// by Value without any custom code:
+//
+//	var post struct {
+//		Title     string           `json:"title"`
+//		CreatedAt firego.Timestamp `json:"createdAt"`
+//	}
+//	err := fb.Value(&post)
+//	fmt.Println(post.CreatedAt.Format(time.RFC3339))
+type Timestamp struct {
+	time.Time
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatInt(t.UnixMilli(), 10)), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+// A null value leaves the timestamp untouched.
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	millis, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		// numbers may be sent in exponent notation
+		f, ferr := strconv.ParseFloat(string(data), 64)
+		if ferr != nil {
+			return fmt.Errorf("invalid timestamp %s: not a number of milliseconds", data)
+		}
+		millis = int64(f)
+	}
+	t.Time = time.UnixMilli(millis)
+	return nil
+}
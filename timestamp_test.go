@@ -0,0 +1,38 @@
+package firego
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimestamp(t *testing.T) {
+	t.Parallel()
+	var (
+		server = newTestServer(`{"title": "hello", "createdAt": 1500000000123, "updatedAt": 1.5e12, "deletedAt": null}`)
+		fb     = New(server.URL, nil)
+	)
+	defer server.Close()
+
+	var post struct {
+		Title     string    `json:"title"`
+		CreatedAt Timestamp `json:"createdAt"`
+		UpdatedAt Timestamp `json:"updatedAt"`
+		DeletedAt Timestamp `json:"deletedAt"`
+	}
+	require.NoError(t, fb.Value(&post))
+	assert.Equal(t, "hello", post.Title)
+	assert.True(t, post.CreatedAt.Equal(time.Unix(1500000000, 123*int64(time.Millisecond))))
+	assert.True(t, post.UpdatedAt.Equal(time.Unix(1500000000, 0)))
+	assert.True(t, post.DeletedAt.IsZero())
+
+	data, err := json.Marshal(post.CreatedAt)
+	require.NoError(t, err)
+	assert.Equal(t, `1500000000123`, string(data))
+
+	var ts Timestamp
+	assert.Error(t, json.Unmarshal([]byte(`"yesterday"`), &ts))
+}
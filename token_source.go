@@ -0,0 +1,51 @@
+package firego
+
+import "golang.org/x/oauth2"
+
+// accessTokenParam is the query parameter used to send OAuth2 access tokens.
+const accessTokenParam = "access_token"
+
+// TokenSource supplies the access token used to authenticate requests.
+// Token is called right before every request is sent, which allows
+// short-lived tokens to be refreshed transparently.
+type TokenSource interface {
+	Token() (string, error)
+}
+
+// TokenSourceFunc is an adapter to allow the use of an ordinary
+// function as a TokenSource.
+type TokenSourceFunc func() (string, error)
+
+// Token calls f().
+func (f TokenSourceFunc) Token() (string, error) {
+	return f()
+}
+
+// OAuth2TokenSource adapts an oauth2.TokenSource, such as the one returned by
+// google.DefaultTokenSource, into a TokenSource. The oauth2.TokenSource is
+// expected to cache and refresh its tokens, oauth2.ReuseTokenSource can be
+// used to achieve this.
+func OAuth2TokenSource(ts oauth2.TokenSource) TokenSource {
+	return TokenSourceFunc(func() (string, error) {
+		token, err := ts.Token()
+		if err != nil {
+			return "", err
+		}
+		return token.AccessToken, nil
+	})
+}
+
+// SetTokenSource sets the source of the OAuth2 access tokens sent with every
+// request, in addition to any token set with Auth. A nil TokenSource stops
+// sending access tokens.
+func (fb *Firebase) SetTokenSource(ts TokenSource) {
+	fb.paramsMtx.Lock()
+	fb.tokenSource = ts
+	fb.paramsMtx.Unlock()
+}
+
+func (fb *Firebase) getTokenSource() TokenSource {
+	fb.paramsMtx.RLock()
+	defer fb.paramsMtx.RUnlock()
+	return fb.tokenSource
+}
@@ -1,82 +1,161 @@
 package firego
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 )
 
 // TransactionFn is used to run a transaction on a Firebase reference.
 // See Firebase.Transaction for more information.
 type TransactionFn func(currentSnapshot interface{}) (result interface{}, err error)
 
-func getTransactionParams(headers http.Header, body []byte) (etag string, snapshot interface{}, err error) {
-	etag = headers.Get("ETag")
+func (fb *Firebase) getTransactionParams(headers http.Header, body []byte) (etag string, snapshot interface{}, err error) {
+	etag = headers.Get(etagHeader)
 	if len(etag) == 0 {
 		return etag, snapshot, errors.New("no etag returned by Firebase")
 	}
 
-	if err := json.Unmarshal(body, &snapshot); err != nil {
+	if err := fb.unmarshal(body, &snapshot); err != nil {
 		return etag, snapshot, fmt.Errorf("failed to unmarshal Firebase response. %s", err)
 	}
 
 	return etag, snapshot, nil
 }
 
+// SetTransactionRetry configures how many times Transaction will attempt to
+// write its result before giving up and how long it waits before each new
+// attempt. A nil backoff retries immediately. A maxAttempts below 1 makes
+// a single attempt.
+func (fb *Firebase) SetTransactionRetry(maxAttempts int, backoff func(attempt int) time.Duration) {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	fb.paramsMtx.Lock()
+	fb.transactionAttempts = maxAttempts
+	fb.transactionBackoff = backoff
+	fb.paramsMtx.Unlock()
+}
+
+func (fb *Firebase) getTransactionRetry() (maxAttempts int, backoff func(attempt int) time.Duration) {
+	fb.paramsMtx.RLock()
+	defer fb.paramsMtx.RUnlock()
+	return fb.transactionAttempts, fb.transactionBackoff
+}
+
 // Transaction runs a transaction on the data at this location. The TransactionFn parameter
 // will be called, possibly multiple times, with the current data at this location.
 // It is responsible for inspecting that data and specifying either the desired new data
-// at the location or that the transaction should be aborted.
+// at the location or that the transaction should be aborted by returning an error,
+// which is then returned by Transaction.
+//
+// The write is retried every time Firebase reports that the data changed
+// in the meantime, up to the number of attempts configured with
+// SetTransactionRetry. ErrTransactionAttemptsExceeded is returned once
+// all attempts have been used.
 //
 // Since the provided function may be called repeatedly for the same transaction, be extremely careful of
 // any side effects that may be triggered by this method.
 //
 // Best practices for this method are to rely only on the data that is passed in.
 func (fb *Firebase) Transaction(fn TransactionFn) error {
+	ctx := context.Background()
+
 	// fetch etag and current value
-	headers, body, err := fb.doRequest("GET", nil, withHeader("X-Firebase-ETag", "true"))
+	headers, body, err := fb.doRequest(ctx, "GET", nil, withHeader(etagRequestHeader, "true"))
 	if err != nil {
 		return err
 	}
 
-	etag, snapshot, err := getTransactionParams(headers, body)
+	etag, snapshot, err := fb.getTransactionParams(headers, body)
 	if err != nil {
 		return err
 	}
 
-	// set the error value to something non-nil so that
-	// we step into the loop
-	tErr := errors.New("")
-	for i := 0; i < 25 && tErr != nil; i++ {
+	maxAttempts, backoff := fb.getTransactionRetry()
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 && backoff != nil {
+			time.Sleep(backoff(attempt))
+		}
+
 		// run transaction
 		result, err := fn(snapshot)
 		if err != nil {
-			return nil
+			return err
 		}
 
-		newBody, err := json.Marshal(result)
+		newBody, err := fb.marshalValue(result)
 		if err != nil {
 			return fmt.Errorf("failed to marshal transaction result. %s", err)
 		}
 
 		// attempt to update it
-		headers, body, tErr = fb.doRequest("PUT", newBody, withHeader("if-match", etag))
-		if tErr == nil {
-			// we're good, break the loop
-			break
+		headers, body, err = fb.doRequest(ctx, "PUT", newBody, withHeader(ifMatchHeader, etag))
+		if err == nil {
+			// we're good
+			return nil
+		}
+
+		if preconditionError(err) != ErrPreconditionFailed {
+			return fmt.Errorf("failed to run transaction. %s", err)
 		}
 
-		// we failed to update, so grab the new snapshot/etag
-		e, s, tErr := getTransactionParams(headers, body)
-		if tErr != nil {
-			return tErr
+		// we failed to update, the response holds the new snapshot/etag
+		etag, snapshot, err = fb.getTransactionParams(headers, body)
+		if err != nil {
+			return err
 		}
-		etag, snapshot = e, s
 	}
 
-	if tErr != nil {
-		return fmt.Errorf("failed to run transaction. %s", tErr)
+	return fmt.Errorf("%w after %d attempts", ErrTransactionAttemptsExceeded, maxAttempts)
+}
+
+// Increment atomically adds delta to the number at this location with a
+// Transaction, a missing value counting as 0, and returns the new number.
+// An error is returned, without writing anything, if the location holds
+// something else than a number. See ServerIncrement for increments that
+// do not need to read the current value.
+func (fb *Firebase) Increment(delta float64) (float64, error) {
+	var newValue float64
+	err := fb.Transaction(func(current interface{}) (interface{}, error) {
+		switch current := current.(type) {
+		case nil:
+			newValue = delta
+		case float64:
+			newValue = current + delta
+		case json.Number:
+			// decoded with UseNumber, see SetDecoderOption
+			f, err := current.Float64()
+			if err != nil {
+				return nil, fmt.Errorf("cannot increment %s: %s", current, err)
+			}
+			newValue = f + delta
+		default:
+			return nil, fmt.Errorf("cannot increment %s: value is not a number", describeJSON(current))
+		}
+		return newValue, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return newValue, nil
+}
+
+// describeJSON returns the JSON type of a decoded value.
+func describeJSON(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "an array"
 	}
-	return nil
+	return fmt.Sprintf("%T", v)
 }
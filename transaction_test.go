@@ -2,11 +2,14 @@ package firego
 
 import (
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -59,7 +62,7 @@ func TestTransaction(t *testing.T) {
 		if req.Header.Get("if-match") != etag {
 			hitConflict.set(true)
 			w.Header().Set("Etag", etag)
-			w.WriteHeader(http.StatusConflict)
+			w.WriteHeader(http.StatusPreconditionFailed)
 			w.Write(valBytes)
 			return
 		}
@@ -83,3 +86,115 @@ func TestTransaction(t *testing.T) {
 	assert.True(t, storedVal.val())
 	assert.True(t, hitConflict.val())
 }
+
+func TestTransactionFnError(t *testing.T) {
+	t.Parallel()
+	server := newETagServer("abc", "1")
+	defer server.Close()
+
+	fnErr := errors.New("abort")
+	fb := New(server.URL, nil)
+	err := fb.Transaction(func(currentSnapshot interface{}) (interface{}, error) {
+		return nil, fnErr
+	})
+	assert.Equal(t, fnErr, err)
+}
+
+func TestTransactionAttemptsExceeded(t *testing.T) {
+	t.Parallel()
+	var puts int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// every write conflicts with a new etag
+		n := atomic.AddInt64(&puts, 1)
+		w.Header().Set(etagHeader, strconv.FormatInt(n, 10))
+		if req.Method != http.MethodGet {
+			w.WriteHeader(http.StatusPreconditionFailed)
+		}
+		w.Write([]byte("1"))
+	}))
+	defer server.Close()
+
+	var backoffs []int
+	fb := New(server.URL, nil)
+	fb.SetTransactionRetry(3, func(attempt int) time.Duration {
+		backoffs = append(backoffs, attempt)
+		return time.Millisecond
+	})
+
+	var calls int
+	err := fb.Transaction(func(currentSnapshot interface{}) (interface{}, error) {
+		calls++
+		return currentSnapshot, nil
+	})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrTransactionAttemptsExceeded))
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []int{1, 2}, backoffs)
+	assert.EqualValues(t, 4, atomic.LoadInt64(&puts))
+
+	// at least one attempt is made
+	fb.SetTransactionRetry(0, nil)
+	calls = 0
+	err = fb.Transaction(func(currentSnapshot interface{}) (interface{}, error) {
+		calls++
+		return currentSnapshot, nil
+	})
+	assert.True(t, errors.Is(err, ErrTransactionAttemptsExceeded))
+	assert.Equal(t, 1, calls)
+}
+
+func TestTransaction_DecoderOption(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set(etagHeader, "etag")
+		w.Write([]byte("12345678901234567890"))
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	fb.SetDecoderOption((*json.Decoder).UseNumber)
+	var snapshot interface{}
+	require.NoError(t, fb.Transaction(func(currentSnapshot interface{}) (interface{}, error) {
+		snapshot = currentSnapshot
+		return currentSnapshot, nil
+	}))
+	assert.Equal(t, json.Number("12345678901234567890"), snapshot)
+}
+
+func TestIncrement(t *testing.T) {
+	t.Parallel()
+	for _, test := range []struct {
+		value    string
+		expected float64
+		err      string
+	}{
+		{value: `41`, expected: 42},
+		{value: `1.5`, expected: 2.5},
+		{value: `null`, expected: 1},
+		{value: `"41"`, err: "cannot increment a string: value is not a number"},
+		{value: `{"a":1}`, err: "cannot increment an object: value is not a number"},
+	} {
+		server := newETagServer("abc", test.value)
+		newValue, err := New(server.URL, nil).Increment(1)
+		server.Close()
+
+		if test.err != "" {
+			assert.EqualError(t, err, test.err, test.value)
+			continue
+		}
+		require.NoError(t, err, test.value)
+		assert.Equal(t, test.expected, newValue, test.value)
+	}
+}
+
+func TestIncrement_UseNumber(t *testing.T) {
+	t.Parallel()
+	server := newETagServer("abc", `41`)
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	fb.SetDecoderOption((*json.Decoder).UseNumber)
+	newValue, err := fb.Increment(1.5)
+	require.NoError(t, err)
+	assert.Equal(t, 42.5, newValue)
+}
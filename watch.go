@@ -3,10 +3,12 @@ package firego
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"log"
-	"net/http"
+	"math/rand"
+	"strings"
 	"time"
 )
 
@@ -20,11 +22,17 @@ const (
 	// EventTypeError is the event type sent when an unknown error is encountered.
 	EventTypeError = "event_error"
 	// EventTypeAuthRevoked is the event type sent when the supplied auth parameter
-	// is no longer valid.
+	// is no longer valid. The event data holds the reason sent by Firebase.
+	// The stream is closed afterwards, a new Watch with a fresh token is needed.
 	EventTypeAuthRevoked = "auth_revoked"
+	// EventTypeCancel is the event type sent when the security rules no longer
+	// allow reading the watched location. The stream is closed afterwards.
+	EventTypeCancel = "cancel"
+	// EventTypeKeepAlive is the event type of the keep-alive messages Firebase
+	// periodically sends on idle streams. They are only sent on the channel
+	// when enabled with SetWatchKeepAlive.
+	EventTypeKeepAlive = "keep-alive"
 
-	eventTypeKeepAlive  = "keep-alive"
-	eventTypeCancel     = "cancel"
 	eventTypeRulesDebug = "rules_debug"
 )
 
@@ -35,83 +43,332 @@ type Event struct {
 	Type string
 	// Path to the data that changed
 	Path string
+	// PathSegments holds the keys of Path, it is empty when the
+	// data at the watched location itself changed
+	PathSegments []string
 	// Data that changed
 	Data interface{}
 
 	rawData []byte
+	ref     *Firebase
+}
+
+// Ref returns a reference to the location of the data that changed,
+// derived from the watched reference and sharing its configuration.
+// It returns nil for events that were not received from a watch.
+func (e Event) Ref() *Firebase {
+	if e.ref == nil {
+		return nil
+	}
+	if len(e.PathSegments) == 0 {
+		return e.ref.copy()
+	}
+	return e.ref.Child(strings.Join(e.PathSegments, "/"))
+}
+
+// pathSegments splits an event path such as /users/u1 into its keys.
+func pathSegments(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
 }
 
 // Value converts the raw payload of the event into the given interface.
 func (e Event) Value(v interface{}) error {
+	unmarshal := json.Unmarshal
+	if e.ref != nil {
+		unmarshal = e.ref.unmarshal
+	}
+	if e.Type != EventTypePut && e.Type != EventTypePatch {
+		// only put and patch events wrap their payload
+		return unmarshal(e.rawData, v)
+	}
+
 	var tmp struct {
-		Data interface{} `json:"data"`
+		Data json.RawMessage `json:"data"`
 	}
-	tmp.Data = &v
-	return json.Unmarshal(e.rawData, &tmp)
+	if err := json.Unmarshal(e.rawData, &tmp); err != nil {
+		return err
+	}
+	return unmarshal(tmp.Data, v)
 }
 
-// StopWatching stops tears down all connections that are watching.
+// StopWatching tears down the watch of the reference and waits until it is
+// over, that is until its connection is closed and the channel given to Watch
+// is closed, which it is only once. After it returns, no more events are sent,
+// including by WatchDebounced, WatchValue and WatchPrefix, and the channel
+// can be left unread. Calling it when the reference is not
+// watching, or again, does nothing. It must not be called from the function
+// given to OnWatchReconnect, which is run by the watch.
 func (fb *Firebase) StopWatching() {
+	fb.watchMtx.Lock()
+	stop, done, forwardDone := fb.stopWatching, fb.watchDone, fb.watchForwardDone
+	fb.watchMtx.Unlock()
+
+	fb.stopWatch(stop)
+	if done != nil {
+		<-done
+	}
+	if forwardDone != nil {
+		<-forwardDone
+	}
+}
+
+// stopWatch tears down the watch identified by the given stop channel,
+// unless it has already been stopped.
+func (fb *Firebase) stopWatch(stop chan struct{}) {
 	fb.watchMtx.Lock()
 	defer fb.watchMtx.Unlock()
 
-	if fb.watching {
+	if fb.watching && fb.stopWatching == stop {
 		// flip the bit back to not watching
 		fb.watching = false
-		// signal connection to terminal
-		fb.stopWatching <- struct{}{}
+		// signal connection to terminate
+		close(stop)
 	}
 }
 
-func (fb *Firebase) setWatching(v bool) {
+// SetWatchReconnect determines whether Watch automatically reconnects when
+// the connection to Firebase drops, either because of a network error or
+// because no data or keep-alive was received within the heartbeat interval.
+// By default, the notifications channel is closed after the error event.
+func (fb *Firebase) SetWatchReconnect(v bool) {
+	fb.watchMtx.Lock()
+	fb.watchReconnect = v
+	fb.watchMtx.Unlock()
+}
+
+// SetWatchKeepAlive determines whether Watch sends the keep-alive messages
+// of Firebase on the channel as EventTypeKeepAlive events, which tells that
+// the connection is alive while the data does not change. By default, they
+// are not sent.
+func (fb *Firebase) SetWatchKeepAlive(v bool) {
+	fb.watchMtx.Lock()
+	fb.watchKeepAlive = v
+	fb.watchMtx.Unlock()
+}
+
+// SetWatchReconnectBackoff configures how long Watch waits before each
+// reconnection attempt. The delay starts at initial and doubles with every
+// failed attempt, up to max. Each delay is shortened by a random amount of
+// up to jitter times the delay, jitter being between 0 and 1, so that many
+// clients do not reconnect all at once. The delay is reset to initial once a
+// connection stayed up for at least max. By default, the delay starts at one
+// second, is capped at 30 seconds and no jitter is applied.
+func (fb *Firebase) SetWatchReconnectBackoff(initial, max time.Duration, jitter float64) {
+	fb.watchMtx.Lock()
+	fb.watchReconnectDelay = initial
+	fb.watchReconnectMax = max
+	fb.watchReconnectJitter = jitter
+	fb.watchMtx.Unlock()
+}
+
+// OnWatchReconnect registers a function that is called before each
+// reconnection attempt of Watch, with the number of the attempt since the
+// connection dropped, starting at 1, and the delay waited before making it.
+func (fb *Firebase) OnWatchReconnect(hook func(attempt int, delay time.Duration)) {
+	fb.watchMtx.Lock()
+	fb.watchReconnectHook = hook
+	fb.watchMtx.Unlock()
+}
+
+// reconnectDelay returns the delay to wait before the given reconnection attempt.
+func (fb *Firebase) reconnectDelay(attempt int) time.Duration {
 	fb.watchMtx.Lock()
-	fb.watching = v
+	delay, max, jitter := fb.watchReconnectDelay, fb.watchReconnectMax, fb.watchReconnectJitter
 	fb.watchMtx.Unlock()
+
+	for i := 1; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	if jitter > 0 {
+		delay -= time.Duration(jitter * rand.Float64() * float64(delay))
+	}
+	return delay
 }
 
 // Watch listens for changes on a firebase instance and
 // passes over to the given chan.
 //
-// Only one connection can be established at a time. The
-// second call to this function without a call to fb.StopWatching
-// will close the channel given and return nil immediately.
+// Only one watch can be made at a time by a reference: until StopWatching is
+// called or the watch is over, Watch returns ErrAlreadyWatching, leaving the
+// given channel untouched. Derived references, such as a Child or a Clone,
+// can be used to watch concurrently.
+//
+// When reconnecting is enabled with SetWatchReconnect, errors that cause the
+// connection to drop are not sent on the channel, a new connection is made
+// instead and the channel stays open until StopWatching is called or Firebase
+// cancels the stream (EventTypeAuthRevoked, EventTypeCancel).
 func (fb *Firebase) Watch(notifications chan Event) error {
+	return fb.WatchWithContext(context.Background(), notifications)
+}
+
+// WatchWithContext behaves like Watch, except that the watch is also torn
+// down, as if StopWatching was called, when the given context is canceled
+// or expires. This includes any connection attempt that is in progress.
+func (fb *Firebase) WatchWithContext(ctx context.Context, notifications chan Event) error {
+	_, err := fb.startWatch(ctx, notifications, nil)
+	return err
+}
+
+// forwardWatch starts watching the reference like Watch for a goroutine
+// forwarding the events sent on events, such as the one of WatchDebounced,
+// returning the stop channel of the watch. The goroutine must close done
+// once it is over, StopWatching waits for it.
+func (fb *Firebase) forwardWatch(events chan Event) (stop, done chan struct{}, err error) {
+	done = make(chan struct{})
+	stop, err = fb.startWatch(context.Background(), events, done)
+	return stop, done, err
+}
+
+// startWatch starts the watch of the reference, see WatchWithContext and
+// forwardWatch, and returns its stop channel. forwardDone is closed here
+// if the watch cannot be started.
+func (fb *Firebase) startWatch(ctx context.Context, notifications chan Event, forwardDone chan struct{}) (chan struct{}, error) {
 	fb.watchMtx.Lock()
 	if fb.watching {
 		fb.watchMtx.Unlock()
-		close(notifications)
-		return nil
+		return nil, ErrAlreadyWatching
 	}
+	stop, done := make(chan struct{}), make(chan struct{})
 	fb.watching = true
+	fb.stopWatching = stop
+	fb.watchDone = done
+	fb.watchForwardDone = forwardDone
+	reconnect := fb.watchReconnect
 	fb.watchMtx.Unlock()
 
-	stop := make(chan struct{})
-	events, err := fb.watch(stop)
+	ctx, cancel := context.WithCancel(ctx)
+	events, err := fb.watch(ctx, stop)
 	if err != nil {
-		return err
+		cancel()
+		fb.stopWatch(stop)
+		close(done)
+		if forwardDone != nil {
+			close(forwardDone)
+		}
+		return nil, err
 	}
 
-	var closedManually bool
-
 	go func() {
-		<-fb.stopWatching
-		closedManually = true
-		stop <- struct{}{}
+		select {
+		case <-ctx.Done():
+			fb.stopWatch(stop)
+		case <-stop:
+			// abort anything that is still in flight
+			cancel()
+		}
 	}()
 
 	go func() {
+		defer close(done)
 		defer close(notifications)
+		// the watch is over once we stop forwarding events
+		defer fb.stopWatch(stop)
+
+		stopped := func() bool {
+			return isStopped(stop) || ctx.Err() != nil
+		}
+
+		var (
+			attempt     int
+			connectedAt = time.Now()
+		)
+		for {
+			var canceled bool
+			for event := range events {
+				if stopped() {
+					// keep draining until the connection is torn down
+					continue
+				}
 
-		for event := range events {
-			if closedManually {
+				switch event.Type {
+				case EventTypeError:
+					if reconnect {
+						continue
+					}
+				case EventTypeAuthRevoked, EventTypeCancel:
+					canceled = true
+				}
+
+				select {
+				case notifications <- event:
+				case <-stop:
+				case <-ctx.Done():
+				}
+			}
+
+			if !reconnect || canceled || stopped() {
 				return
 			}
 
-			notifications <- event
+			fb.watchMtx.Lock()
+			stable := time.Since(connectedAt) >= fb.watchReconnectMax
+			fb.watchMtx.Unlock()
+			if stable {
+				attempt = 0
+			}
+
+			if events = fb.rewatch(ctx, stop, notifications, &attempt); events == nil {
+				return
+			}
+			connectedAt = time.Now()
 		}
 	}()
 
-	return nil
+	return stop, nil
+}
+
+// rewatch tries to establish a new connection until it succeeds, the watch
+// is stopped or Firebase rejects the request. It returns nil when giving up.
+// The attempts made are counted in attempt.
+func (fb *Firebase) rewatch(ctx context.Context, stop chan struct{}, notifications chan Event, attempt *int) chan Event {
+	for {
+		*attempt++
+		delay := fb.reconnectDelay(*attempt)
+
+		fb.watchMtx.Lock()
+		hook := fb.watchReconnectHook
+		fb.watchMtx.Unlock()
+		if hook != nil {
+			hook(*attempt, delay)
+		}
+
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(delay):
+		}
+
+		events, err := fb.watch(ctx, stop)
+		if err == nil {
+			return events
+		}
+
+		var fbErr *FirebaseError
+		if errors.As(err, &fbErr) && fbErr.StatusCode < 500 {
+			// retrying won't help, tell the caller why we stopped
+			select {
+			case notifications <- Event{Type: EventTypeError, Data: err}:
+			case <-stop:
+			}
+			return nil
+		}
+	}
+}
+
+func isStopped(stop chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
 }
 
 func readLine(rdr *bufio.Reader, prefix string) ([]byte, error) {
@@ -140,27 +397,44 @@ func readLine(rdr *bufio.Reader, prefix string) ([]byte, error) {
 	return bytes.TrimSpace(line), nil
 }
 
-func (fb *Firebase) watch(stop chan struct{}) (chan Event, error) {
+func (fb *Firebase) watch(ctx context.Context, stop chan struct{}) (chan Event, error) {
+	// the stream is aborted by canceling its request, closing the body
+	// while it is being read is not safe
+	ctx, cancel := context.WithCancel(ctx)
+
 	// build SSE request
-	req, err := http.NewRequest("GET", fb.String(), nil)
+	req, err := fb.newRequest(ctx, "GET", nil)
 	if err != nil {
-		fb.setWatching(false)
+		cancel()
 		return nil, err
 	}
 	req.Header.Add("Accept", "text/event-stream")
 
 	// do request
-	resp, err := fb.client.Do(req)
+	resp, err := fb.roundTrip(ctx, req)
 	if err != nil {
-		fb.setWatching(false)
+		cancel()
+		return nil, err
+	}
+
+	if err := streamError(resp); err != nil {
+		closeBody(resp.Body)
+		cancel()
 		return nil, err
 	}
 
+	fb.watchMtx.Lock()
+	keepAlive := fb.watchKeepAlive
+	fb.watchMtx.Unlock()
+
 	notifications := make(chan Event)
 
 	go func() {
-		<-stop
-		resp.Body.Close()
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
 	}()
 
 	heartbeat := make(chan struct{})
@@ -169,8 +443,10 @@ func (fb *Firebase) watch(stop chan struct{}) (chan Event, error) {
 			select {
 			case <-heartbeat:
 				// do nothing
+			case <-ctx.Done():
+				return
 			case <-time.After(fb.watchHeartbeat):
-				resp.Body.Close()
+				cancel()
 				return
 			}
 		}
@@ -180,6 +456,7 @@ func (fb *Firebase) watch(stop chan struct{}) (chan Event, error) {
 	go func() {
 		defer func() {
 			resp.Body.Close()
+			cancel()
 			close(notifications)
 		}()
 
@@ -229,20 +506,27 @@ func (fb *Firebase) watch(stop chan struct{}) (chan Event, error) {
 			case EventTypePut, EventTypePatch:
 				// we've got extra data we've got to parse
 				var data map[string]interface{}
-				if err := json.Unmarshal(event.rawData, &data); err != nil {
+				if err := fb.unmarshal(event.rawData, &data); err != nil {
 					sendError(err)
 					return
 				}
 
 				// set the extra fields
 				event.Path = data["path"].(string)
+				event.PathSegments = pathSegments(event.Path)
 				event.Data = data["data"]
+				event.ref = fb
 
 				// ship it
 				notifications <- event
-			case eventTypeKeepAlive:
-				// received ping - nothing to do here
-			case eventTypeCancel:
+			case EventTypeKeepAlive:
+				// received ping, only shipped if asked for
+				if keepAlive {
+					event.Data = nil
+					event.ref = fb
+					notifications <- event
+				}
+			case EventTypeCancel:
 				// The data for this event is null
 				// This event will be sent if the Security and Firebase Rules
 				// cause a read at the requested location to no longer be allowed
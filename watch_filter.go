@@ -0,0 +1,126 @@
+package firego
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// WatchPrefix watches the reference like Watch, but only sends the put and
+// patch events that change the data below the given path, relative to the
+// reference, other events such as errors being sent as is.
+//
+// Events made at a parent of the path, such as the put of the whole value
+// that starts every stream, are cut down to the data below the path: a put
+// is turned into a put at the path of the data it holds for the path, null
+// if it holds none, and a patch into a put or patch at the path of the
+// changes it makes to it. Such events are marshaled again, numbers losing
+// the precision they do not have as float64 values.
+//
+// Watching the child reference instead gets the same events with paths
+// relative to it, but WatchPrefix keeps the paths relative to the reference.
+func (fb *Firebase) WatchPrefix(prefix string, notifications chan Event) error {
+	events := make(chan Event)
+	stop, done, err := fb.forwardWatch(events)
+	if err != nil {
+		return err
+	}
+
+	segments := pathSegments(prefix)
+	go func() {
+		defer close(done)
+		defer close(notifications)
+		for event := range events {
+			event, ok := filterEvent(event, segments)
+			if !ok {
+				continue
+			}
+			if isStopped(stop) {
+				return
+			}
+			select {
+			case notifications <- event:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// filterEvent returns the part of the event that changes the data below the
+// given path, reporting whether there is one.
+func filterEvent(event Event, prefix []string) (Event, bool) {
+	if event.Type != EventTypePut && event.Type != EventTypePatch {
+		return event, true
+	}
+	if hasSegments(event.PathSegments, prefix) {
+		return event, true
+	}
+	if !hasSegments(prefix, event.PathSegments) {
+		// the event is made elsewhere
+		return event, false
+	}
+
+	rest := prefix[len(event.PathSegments):]
+	if event.Type == EventTypePut {
+		return prefixEvent(event, EventTypePut, prefix, subtree(event.Data, rest)), true
+	}
+
+	changes, _ := event.Data.(map[string]interface{})
+	patch := map[string]interface{}{}
+	for key, value := range changes {
+		segments := pathSegments(key)
+		switch {
+		case hasSegments(rest, segments):
+			// Firebase rejects updates of overlapping paths, the
+			// key replacing the data of the path is the only one
+			return prefixEvent(event, EventTypePut, prefix, subtree(value, rest[len(segments):])), true
+		case hasSegments(segments, rest):
+			patch[strings.Join(segments[len(rest):], "/")] = value
+		}
+	}
+	if len(patch) == 0 {
+		return event, false
+	}
+	return prefixEvent(event, EventTypePatch, prefix, patch), true
+}
+
+// prefixEvent returns the event of the given type made at the
+// given path with the given data in place of the given event.
+func prefixEvent(event Event, typ string, path []string, data interface{}) Event {
+	event.Type = typ
+	event.Path = "/" + strings.Join(path, "/")
+	event.PathSegments = path
+	event.Data = data
+	event.rawData, _ = json.Marshal(map[string]interface{}{
+		"path": event.Path,
+		"data": data,
+	})
+	return event
+}
+
+// subtree returns the data found at the given path of the given data.
+func subtree(data interface{}, path []string) interface{} {
+	for _, key := range path {
+		children, ok := data.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		data = children[key]
+	}
+	return data
+}
+
+// hasSegments reports whether the path made of the given segments
+// is the path made of the given prefix or one of its children.
+func hasSegments(segments, prefix []string) bool {
+	if len(segments) < len(prefix) {
+		return false
+	}
+	for i, key := range prefix {
+		if segments[i] != key {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+package firego
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterEvent(t *testing.T) {
+	t.Parallel()
+	prefix := []string{"users", "alice"}
+	for _, test := range []struct {
+		event    Event
+		expected *Event
+	}{
+		{
+			event:    Event{Type: EventTypePut, Path: "/users/alice/age", Data: float64(30)},
+			expected: &Event{Type: EventTypePut, Path: "/users/alice/age", Data: float64(30)},
+		},
+		{
+			event:    Event{Type: EventTypePut, Path: "/users/bob", Data: "x"},
+			expected: nil,
+		},
+		{
+			event:    Event{Type: EventTypePut, Path: "/users/alicia", Data: "x"},
+			expected: nil,
+		},
+		{
+			event: Event{Type: EventTypePut, Path: "/", Data: map[string]interface{}{
+				"users": map[string]interface{}{"alice": map[string]interface{}{"age": float64(30)}, "bob": true},
+			}},
+			expected: &Event{Type: EventTypePut, Path: "/users/alice", Data: map[string]interface{}{"age": float64(30)}},
+		},
+		{
+			event:    Event{Type: EventTypePut, Path: "/", Data: map[string]interface{}{"other": true}},
+			expected: &Event{Type: EventTypePut, Path: "/users/alice", Data: nil},
+		},
+		{
+			event:    Event{Type: EventTypePatch, Path: "/users", Data: map[string]interface{}{"alice/age": float64(31), "bob/age": float64(40)}},
+			expected: &Event{Type: EventTypePatch, Path: "/users/alice", Data: map[string]interface{}{"age": float64(31)}},
+		},
+		{
+			event:    Event{Type: EventTypePatch, Path: "/", Data: map[string]interface{}{"users": map[string]interface{}{"alice": "x"}, "a": true}},
+			expected: &Event{Type: EventTypePut, Path: "/users/alice", Data: "x"},
+		},
+		{
+			event:    Event{Type: EventTypePatch, Path: "/users", Data: map[string]interface{}{"bob": true}},
+			expected: nil,
+		},
+		{
+			event:    Event{Type: EventTypeError, Data: "error"},
+			expected: &Event{Type: EventTypeError, Data: "error"},
+		},
+	} {
+		test.event.PathSegments = pathSegments(test.event.Path)
+		event, ok := filterEvent(test.event, prefix)
+		if test.expected == nil {
+			assert.False(t, ok, "%+v", test.event)
+			continue
+		}
+		require.True(t, ok, "%+v", test.event)
+		assert.Equal(t, test.expected.Type, event.Type, "%+v", test.event)
+		assert.Equal(t, test.expected.Path, event.Path, "%+v", test.event)
+		assert.Equal(t, pathSegments(test.expected.Path), event.PathSegments, "%+v", test.event)
+		assert.Equal(t, test.expected.Data, event.Data, "%+v", test.event)
+	}
+}
+
+func TestWatchPrefix(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, data := range []string{
+			`{"path":"/","data":{"users":{"alice":{"age":30}},"posts":{"p1":true}}}`,
+			`{"path":"/posts/p2","data":true}`,
+			`{"path":"/users/alice/age","data":31}`,
+		} {
+			fmt.Fprintf(w, "event: put\ndata: %s\n\n", data)
+		}
+		w.(http.Flusher).Flush()
+		<-req.Context().Done()
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	notifications := make(chan Event)
+	require.NoError(t, fb.WatchPrefix("/users/", notifications))
+
+	event := <-notifications
+	assert.Equal(t, "/users", event.Path)
+	var users map[string]map[string]int
+	require.NoError(t, event.Value(&users))
+	assert.Equal(t, map[string]map[string]int{"alice": {"age": 30}}, users)
+
+	event = <-notifications
+	assert.Equal(t, "/users/alice/age", event.Path)
+	assert.Equal(t, float64(31), event.Data)
+
+	fb.StopWatching()
+	for range notifications {
+	}
+}
+
+func TestWatchPrefix_StopWatching(t *testing.T) {
+	t.Parallel()
+	testStopWatchingForwarded(t, func(fb *Firebase) interface{} {
+		notifications := make(chan Event)
+		require.NoError(t, fb.WatchPrefix("/", notifications))
+		return notifications
+	})
+}
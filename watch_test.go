@@ -1,10 +1,15 @@
 package firego
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -54,6 +59,36 @@ func TestWatch(t *testing.T) {
 	}
 }
 
+func TestWatchEventRef(t *testing.T) {
+	t.Parallel()
+	server := firetest.New()
+	server.Start()
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	notifications := make(chan Event)
+	require.NoError(t, fb.Watch(notifications))
+	defer fb.StopWatching()
+
+	event := <-notifications
+	assert.Equal(t, "/", event.Path)
+	assert.Empty(t, event.PathSegments)
+	assert.Equal(t, fb.URL(), event.Ref().URL())
+
+	server.Set("/users/u 1/name", "alice")
+	event = <-notifications
+	assert.Equal(t, "/users/u 1/name", event.Path)
+	assert.Equal(t, []string{"users", "u 1", "name"}, event.PathSegments)
+
+	var name string
+	require.NoError(t, event.Ref().Value(&name))
+	assert.Equal(t, "alice", name)
+	require.NoError(t, event.Ref().Set("bob"))
+	assert.Equal(t, "bob", server.Get("/users/u 1/name"))
+
+	assert.Nil(t, Event{}.Ref())
+}
+
 func TestWatchRedirectPreservesHeader(t *testing.T) {
 	t.Parallel()
 
@@ -175,6 +210,42 @@ func TestWatchAuthRevoked(t *testing.T) {
 	assert.Equal(t, EventTypeAuthRevoked, event.Type, "event type doesn't match")
 	assert.Empty(t, event.Path, "event path is not empty")
 	assert.Equal(t, event.Data, `"token expired"`, "event data does not match")
+
+	var reason string
+	require.NoError(t, event.Value(&reason))
+	assert.Equal(t, "token expired", reason)
+}
+
+func TestWatchCanceledNoReconnect(t *testing.T) {
+	t.Parallel()
+
+	for _, eventType := range []string{EventTypeCancel, EventTypeAuthRevoked} {
+		var conns int64
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			atomic.AddInt64(&conns, 1)
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprintf(w, "event: %s\ndata: null\n\n", eventType)
+		}))
+
+		fb := New(server.URL, nil)
+		fb.watchReconnectDelay = time.Millisecond
+		fb.SetWatchReconnect(true)
+
+		notifications := make(chan Event)
+		require.NoError(t, fb.Watch(notifications))
+
+		event, ok := <-notifications
+		require.True(t, ok, "notifications closed")
+		assert.Equal(t, eventType, event.Type)
+
+		_, ok = <-notifications
+		assert.False(t, ok, "notifications should be closed")
+
+		// give a potential reconnect the chance to happen
+		time.Sleep(20 * time.Millisecond)
+		assert.EqualValues(t, 1, atomic.LoadInt64(&conns))
+		server.Close()
+	}
 }
 
 func TestWatch_Issue66(t *testing.T) {
@@ -230,3 +301,435 @@ func TestStopWatch(t *testing.T) {
 	_, ok := <-notifications
 	assert.False(t, ok, "notifications should be closed")
 }
+
+func TestStopWatchingWaits(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; ; i++ {
+			if _, err := fmt.Fprintf(w, "event: put\ndata: {\"path\":\"/\",\"data\":%d}\n\n", i); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+			time.Sleep(time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	notifications := make(chan Event)
+	require.NoError(t, fb.Watch(notifications))
+	<-notifications
+
+	// the notifications are not read while stopping
+	fb.StopWatching()
+	select {
+	case _, ok := <-notifications:
+		assert.False(t, ok, "notifications should be closed")
+	default:
+		assert.Fail(t, "notifications should be closed once StopWatching returns")
+	}
+
+	// stopping again is a no-op
+	fb.StopWatching()
+	New(server.URL, nil).StopWatching()
+}
+
+// testStopWatchingForwarded checks that the channel of the watch started by
+// watch, such as the one of WatchDebounced, is closed once StopWatching
+// returns while events keep coming.
+func testStopWatchingForwarded(t *testing.T, watch func(fb *Firebase) interface{}) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; ; i++ {
+			if _, err := fmt.Fprintf(w, "event: put\ndata: {\"path\":\"/\",\"data\":%d}\n\n", i); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+			time.Sleep(time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	for i := 0; i < 20; i++ {
+		fb := New(server.URL, nil)
+		ch := reflect.ValueOf(watch(fb))
+		_, ok := ch.Recv()
+		require.True(t, ok)
+
+		// the channel is not read while stopping
+		fb.StopWatching()
+
+		chosen, _, ok := reflect.Select([]reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: ch},
+			{Dir: reflect.SelectDefault},
+		})
+		require.Equal(t, 0, chosen, "the channel should be closed once StopWatching returns")
+		require.False(t, ok, "no event should be sent once StopWatching returns")
+	}
+}
+
+func TestWatchAlreadyWatching(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: put\ndata: {\"path\":\"/\",\"data\":null}\n\n")
+		w.(http.Flusher).Flush()
+		<-req.Context().Done()
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	var (
+		wg       sync.WaitGroup
+		watching int32
+		rejected int32
+		channels = make([]chan Event, 5)
+	)
+	for i := range channels {
+		channels[i] = make(chan Event, 1)
+		wg.Add(1)
+		go func(notifications chan Event) {
+			defer wg.Done()
+			switch err := fb.Watch(notifications); err {
+			case nil:
+				atomic.AddInt32(&watching, 1)
+			case ErrAlreadyWatching:
+				atomic.AddInt32(&rejected, 1)
+			default:
+				assert.NoError(t, err)
+			}
+		}(channels[i])
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), watching)
+	assert.Equal(t, int32(4), rejected)
+
+	// the channels of the rejected calls are left open
+	fb.StopWatching()
+	var closed int
+	for _, notifications := range channels {
+	drain:
+		for {
+			select {
+			case _, ok := <-notifications:
+				if !ok {
+					closed++
+					break drain
+				}
+			default:
+				break drain
+			}
+		}
+	}
+	assert.Equal(t, 1, closed)
+
+	// a child watches independently and the reference can watch again
+	child := make(chan Event)
+	childRef := fb.Child("child")
+	require.NoError(t, childRef.Watch(child))
+	require.NoError(t, fb.Watch(make(chan Event)))
+	assert.Equal(t, ErrAlreadyWatching, fb.Watch(make(chan Event)))
+	fb.StopWatching()
+	childRef.StopWatching()
+}
+
+func TestWatchKeepAlive(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: keep-alive\ndata: null\n\n")
+		fmt.Fprint(w, "event: put\ndata: {\"path\":\"/\",\"data\":1}\n\n")
+		fmt.Fprint(w, "event: keep-alive\ndata: null\n\n")
+		w.(http.Flusher).Flush()
+		<-req.Context().Done()
+	}))
+	defer server.Close()
+
+	for _, keepAlive := range []bool{false, true} {
+		fb := New(server.URL, nil)
+		fb.SetWatchKeepAlive(keepAlive)
+		notifications := make(chan Event)
+		require.NoError(t, fb.Watch(notifications))
+
+		var types []string
+		expected := []string{EventTypePut}
+		if keepAlive {
+			expected = []string{EventTypeKeepAlive, EventTypePut, EventTypeKeepAlive}
+		}
+		for range expected {
+			select {
+			case event := <-notifications:
+				types = append(types, event.Type)
+			case <-time.After(time.Second):
+				require.FailNow(t, "did not receive an event")
+			}
+		}
+		assert.Equal(t, expected, types)
+		select {
+		case event := <-notifications:
+			assert.Fail(t, "unexpected event", "%+v", event)
+		case <-time.After(50 * time.Millisecond):
+		}
+		fb.StopWatching()
+	}
+}
+
+func TestWatchReconnect(t *testing.T) {
+	t.Parallel()
+
+	var conns int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt64(&conns, 1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "event: put\ndata: {\"path\":\"/\",\"data\":%d}\n\n", n)
+		// returning drops the connection
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	fb.watchReconnectDelay = time.Millisecond
+	fb.SetWatchReconnect(true)
+
+	notifications := make(chan Event)
+	require.NoError(t, fb.Watch(notifications))
+
+	for i := 1; i <= 3; i++ {
+		select {
+		case event, ok := <-notifications:
+			require.True(t, ok, "notifications closed")
+			assert.Equal(t, EventTypePut, event.Type)
+			assert.EqualValues(t, i, event.Data)
+		case <-time.After(time.Second):
+			require.FailNow(t, "did not reconnect")
+		}
+	}
+
+	fb.StopWatching()
+	for range notifications {
+	}
+}
+
+func TestWatchReconnectRejected(t *testing.T) {
+	t.Parallel()
+
+	var conns int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt64(&conns, 1) > 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "event: put\ndata: {\"path\":\"/\",\"data\":null}\n\n")
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	fb.watchReconnectDelay = time.Millisecond
+	fb.SetWatchReconnect(true)
+
+	notifications := make(chan Event)
+	require.NoError(t, fb.Watch(notifications))
+
+	event := <-notifications
+	assert.Equal(t, EventTypePut, event.Type)
+
+	event = <-notifications
+	assert.Equal(t, EventTypeError, event.Type)
+	assert.True(t, IsUnauthorized(event.Data.(error)))
+
+	_, ok := <-notifications
+	assert.False(t, ok, "notifications should be closed")
+}
+
+func TestReconnectDelay(t *testing.T) {
+	t.Parallel()
+	fb := New(URL, nil)
+	fb.SetWatchReconnectBackoff(10*time.Millisecond, 80*time.Millisecond, 0)
+	for attempt, expected := range []time.Duration{10, 20, 40, 80, 80, 80} {
+		assert.Equal(t, expected*time.Millisecond, fb.reconnectDelay(attempt+1), "attempt %d", attempt+1)
+	}
+
+	fb.SetWatchReconnectBackoff(10*time.Millisecond, 80*time.Millisecond, 0.5)
+	for i := 0; i < 100; i++ {
+		delay := fb.reconnectDelay(3)
+		assert.True(t, delay >= 20*time.Millisecond && delay <= 40*time.Millisecond, "delay: %s", delay)
+	}
+}
+
+func TestWatchReconnectBackoff(t *testing.T) {
+	t.Parallel()
+
+	var conns int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if n := atomic.AddInt64(&conns, 1); n > 1 && n < 5 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "event: put\ndata: {\"path\":\"/\",\"data\":null}\n\n")
+	}))
+	defer server.Close()
+
+	var (
+		mtx      sync.Mutex
+		attempts []int
+		delays   []time.Duration
+	)
+	fb := New(server.URL, nil)
+	fb.SetWatchReconnect(true)
+	fb.SetWatchReconnectBackoff(time.Millisecond, time.Hour, 0)
+	fb.OnWatchReconnect(func(attempt int, delay time.Duration) {
+		mtx.Lock()
+		attempts = append(attempts, attempt)
+		delays = append(delays, delay)
+		mtx.Unlock()
+	})
+
+	notifications := make(chan Event)
+	require.NoError(t, fb.Watch(notifications))
+	for i := 0; i < 2; i++ {
+		select {
+		case event := <-notifications:
+			assert.Equal(t, EventTypePut, event.Type)
+		case <-time.After(time.Second):
+			require.FailNow(t, "did not receive a notification")
+		}
+	}
+	fb.StopWatching()
+	for range notifications {
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	require.True(t, len(attempts) >= 4, "attempts: %v", attempts)
+	// the connection that succeeded was not stable, the backoff keeps growing
+	assert.Equal(t, []int{1, 2, 3, 4}, attempts[:4])
+	assert.Equal(t, []time.Duration{time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond, 8 * time.Millisecond}, delays[:4])
+}
+
+func TestWatchReconnectBackoffReset(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "event: put\ndata: {\"path\":\"/\",\"data\":null}\n\n")
+		w.(http.Flusher).Flush()
+		// stay connected for longer than the maximum delay
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	attempts := make(chan int, 10)
+	fb := New(server.URL, nil)
+	fb.SetWatchReconnect(true)
+	fb.SetWatchReconnectBackoff(time.Millisecond, 5*time.Millisecond, 0)
+	fb.OnWatchReconnect(func(attempt int, delay time.Duration) {
+		select {
+		case attempts <- attempt:
+		default:
+		}
+	})
+
+	notifications := make(chan Event)
+	require.NoError(t, fb.Watch(notifications))
+	go func() {
+		for range notifications {
+		}
+	}()
+	defer fb.StopWatching()
+
+	for i := 0; i < 3; i++ {
+		select {
+		case attempt := <-attempts:
+			assert.Equal(t, 1, attempt)
+		case <-time.After(time.Second):
+			require.FailNow(t, "did not reconnect")
+		}
+	}
+}
+
+func TestWatchDecoderOption(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "event: put\ndata: %s\n\n", `{"path":"/","data":9007199254740993}`)
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	fb.SetDecoderOption((*json.Decoder).UseNumber)
+
+	notifications := make(chan Event)
+	require.NoError(t, fb.Watch(notifications))
+
+	event := <-notifications
+	assert.Equal(t, json.Number("9007199254740993"), event.Data)
+}
+
+func TestWatchWithContext(t *testing.T) {
+	t.Parallel()
+	server := firetest.New()
+	server.Start()
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	notifications := make(chan Event)
+	require.NoError(t, fb.WatchWithContext(ctx, notifications))
+	<-notifications // get initial notification
+
+	cancel()
+	select {
+	case _, ok := <-notifications:
+		assert.False(t, ok, "notifications should be closed")
+	case <-time.After(time.Second):
+		require.FailNow(t, "watch was not stopped")
+	}
+
+	// stopping a watch that was already torn down is a no-op
+	fb.StopWatching()
+}
+
+func TestWatchWithContextCanceledWhileReconnecting(t *testing.T) {
+	t.Parallel()
+
+	var conns int64
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt64(&conns, 1) > 1 {
+			// never answer the reconnection attempt
+			select {
+			case <-done:
+			case <-req.Context().Done():
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "event: put\ndata: {\"path\":\"/\",\"data\":null}\n\n")
+	}))
+	defer server.Close()
+	defer close(done)
+
+	fb := New(server.URL, nil)
+	fb.watchReconnectDelay = time.Millisecond
+	fb.SetWatchReconnect(true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	notifications := make(chan Event)
+	require.NoError(t, fb.WatchWithContext(ctx, notifications))
+	<-notifications
+
+	// wait for the reconnection attempt to be in progress
+	for atomic.LoadInt64(&conns) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-notifications:
+		assert.False(t, ok, "notifications should be closed")
+	case <-time.After(time.Second):
+		require.FailNow(t, "watch was not stopped")
+	}
+}
@@ -0,0 +1,188 @@
+package firego
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// WatchValue watches the reference like Watch and keeps a copy of its data
+// in memory, to which every put and patch event is applied. Each time the
+// data changes, it is decoded into a new value of the type of prototype and
+// sent on values; a pointer prototype such as &User{} yields pointers to new
+// values, any other prototype yields values. Error events, as well as data
+// that cannot be decoded, are sent on values as errors.
+//
+// The whole node is held in memory and decoded again on every change, which
+// is only suitable for locations holding a reasonable amount of data.
+//
+// Like Watch, values is closed once the watch is over, see StopWatching.
+func (fb *Firebase) WatchValue(prototype interface{}, values chan interface{}) error {
+	return fb.watchValue(prototype, values, false)
+}
+
+// GetAndWatch watches the reference like WatchValue, with the guarantee that
+// the first value sent on values is the complete current value of the
+// reference, or the error that prevented reading it, and that the following
+// values are the changes made after it. Firebase starts every stream with
+// the current value, which is used as is; should the stream start otherwise,
+// the value is read with a Get before applying the first event. Error events
+// are sent as errors, like with WatchValue, and may come first.
+func (fb *Firebase) GetAndWatch(prototype interface{}, values chan interface{}) error {
+	return fb.watchValue(prototype, values, true)
+}
+
+func (fb *Firebase) watchValue(prototype interface{}, values chan interface{}, initial bool) error {
+	events := make(chan Event)
+	stop, done, err := fb.forwardWatch(events)
+	if err != nil {
+		return err
+	}
+
+	typ := reflect.TypeOf(prototype)
+	go func() {
+		defer close(done)
+		defer close(values)
+
+		var data interface{}
+		for event := range events {
+			var value interface{}
+			switch event.Type {
+			case EventTypePut, EventTypePatch:
+				if initial && (event.Type != EventTypePut || event.Path != "/") {
+					// the event changes a value that is yet to be read
+					current, err := fb.currentValue()
+					if err != nil {
+						value = err
+						break
+					}
+					data = current
+				}
+				initial = false
+				data = applyEvent(data, event)
+			case EventTypeError:
+				value = event.Data
+			default:
+				continue
+			}
+
+			if value == nil {
+				var err error
+				if value, err = fb.decodeValue(data, typ); err != nil {
+					value = err
+				}
+			}
+			if isStopped(stop) {
+				return
+			}
+			select {
+			case values <- value:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// currentValue reads the value of the reference, returning the error
+// that prevented it, if any.
+func (fb *Firebase) currentValue() (interface{}, error) {
+	bytes, err := fb.GetRaw()
+	if err != nil {
+		return nil, err
+	}
+	var data interface{}
+	if err := fb.unmarshal(bytes, &data); err != nil {
+		return nil, err
+	}
+	return normalize(data), nil
+}
+
+// decodeValue decodes the given data into a new value of the given type.
+func (fb *Firebase) decodeValue(data interface{}, typ reflect.Type) (interface{}, error) {
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if typ == nil {
+		var v interface{}
+		err := fb.unmarshal(bytes, &v)
+		return v, err
+	}
+	if typ.Kind() == reflect.Ptr {
+		v := reflect.New(typ.Elem())
+		err := fb.unmarshal(bytes, v.Interface())
+		return v.Interface(), err
+	}
+	v := reflect.New(typ)
+	err = fb.unmarshal(bytes, v.Interface())
+	return v.Elem().Interface(), err
+}
+
+// applyEvent returns the given data once the put or patch event is applied.
+// A put replaces the data at the event path, null deleting it. A patch sets
+// every key of its data, which may be a path, below the event path: like for
+// an update, the children it holds replace the existing ones rather than
+// being merged into them.
+//
+// Reference https://firebase.google.com/docs/reference/rest/database#section-streaming
+func applyEvent(data interface{}, event Event) interface{} {
+	segments := pathSegments(event.Path)
+	if event.Type == EventTypePut {
+		return setPath(data, segments, normalize(event.Data))
+	}
+
+	changes, _ := event.Data.(map[string]interface{})
+	for key, value := range changes {
+		path := append(segments[:len(segments):len(segments)], pathSegments(key)...)
+		data = setPath(data, path, normalize(value))
+	}
+	return data
+}
+
+// normalize removes the null and empty children of the given value, which
+// do not exist for Firebase. It returns nil if nothing is left.
+func normalize(value interface{}) interface{} {
+	children, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+	for key, child := range children {
+		if child = normalize(child); child == nil {
+			delete(children, key)
+		} else {
+			children[key] = child
+		}
+	}
+	if len(children) == 0 {
+		return nil
+	}
+	return children
+}
+
+// setPath returns the given data with the value at path replaced,
+// a nil value removes the location and the empty parents it leaves.
+func setPath(data interface{}, path []string, value interface{}) interface{} {
+	if len(path) == 0 {
+		return value
+	}
+
+	children, ok := data.(map[string]interface{})
+	if !ok {
+		if value == nil {
+			return data
+		}
+		children = map[string]interface{}{}
+	}
+	child := setPath(children[path[0]], path[1:], value)
+	if child == nil {
+		delete(children, path[0])
+	} else {
+		children[path[0]] = child
+	}
+	if len(children) == 0 {
+		return nil
+	}
+	return children
+}
@@ -0,0 +1,273 @@
+package firego
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchValue(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`put`, `{"path":"/","data":{"name":"alice","tags":{"a":true}}}`,
+			`patch`, `{"path":"/","data":{"age":30,"tags/b":true}}`,
+			`put`, `{"path":"/tags/a","data":null}`,
+			`put`, `{"path":"/tags/b","data":null}`,
+			`put`, `{"path":"/age","data":"unknown"}`,
+		} {
+			if event[0] == '{' {
+				fmt.Fprintf(w, "data: %s\n\n", event)
+			} else {
+				fmt.Fprintf(w, "event: %s\n", event)
+			}
+		}
+		w.(http.Flusher).Flush()
+		<-req.Context().Done()
+	}))
+	defer server.Close()
+
+	type user struct {
+		Name string          `json:"name"`
+		Age  int             `json:"age"`
+		Tags map[string]bool `json:"tags"`
+	}
+
+	fb := New(server.URL, nil)
+	values := make(chan interface{})
+	require.NoError(t, fb.WatchValue(&user{}, values))
+
+	assert.Equal(t, &user{Name: "alice", Tags: map[string]bool{"a": true}}, <-values)
+	assert.Equal(t, &user{Name: "alice", Age: 30, Tags: map[string]bool{"a": true, "b": true}}, <-values)
+	assert.Equal(t, &user{Name: "alice", Age: 30, Tags: map[string]bool{"b": true}}, <-values)
+	assert.Equal(t, &user{Name: "alice", Age: 30}, <-values)
+	_, ok := (<-values).(error)
+	assert.True(t, ok, "undecodable data is sent as an error")
+
+	fb.StopWatching()
+	for range values {
+	}
+}
+
+func TestGetAndWatch(t *testing.T) {
+	t.Parallel()
+	var gets int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Accept") != "text/event-stream" {
+			atomic.AddInt32(&gets, 1)
+			fmt.Fprint(w, `{"a":1,"b":2}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		// the stream does not start with the current value
+		fmt.Fprint(w, "event: patch\ndata: {\"path\":\"/\",\"data\":{\"b\":3}}\n\n")
+		fmt.Fprint(w, "event: put\ndata: {\"path\":\"/a\",\"data\":null}\n\n")
+		w.(http.Flusher).Flush()
+		<-req.Context().Done()
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	values := make(chan interface{})
+	require.NoError(t, fb.GetAndWatch(map[string]int{}, values))
+	assert.Equal(t, map[string]int{"a": 1, "b": 3}, <-values)
+	assert.Equal(t, map[string]int{"b": 3}, <-values)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&gets))
+	fb.StopWatching()
+	for range values {
+	}
+}
+
+func TestGetAndWatch_DecoderOption(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Accept") != "text/event-stream" {
+			fmt.Fprint(w, `{"a":9007199254740993}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: patch\ndata: {\"path\":\"/\",\"data\":{\"b\":9007199254740995}}\n\n")
+		w.(http.Flusher).Flush()
+		<-req.Context().Done()
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	fb.SetDecoderOption((*json.Decoder).UseNumber)
+	values := make(chan interface{})
+	require.NoError(t, fb.GetAndWatch(map[string]int64{}, values))
+	assert.Equal(t, map[string]int64{"a": 9007199254740993, "b": 9007199254740995}, <-values)
+	fb.StopWatching()
+	for range values {
+	}
+}
+
+func TestGetAndWatch_InitialPut(t *testing.T) {
+	t.Parallel()
+	var gets int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Accept") != "text/event-stream" {
+			atomic.AddInt32(&gets, 1)
+			fmt.Fprint(w, `null`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: put\ndata: {\"path\":\"/\",\"data\":{\"a\":1}}\n\n")
+		fmt.Fprint(w, "event: patch\ndata: {\"path\":\"/\",\"data\":{\"b\":2}}\n\n")
+		w.(http.Flusher).Flush()
+		<-req.Context().Done()
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	values := make(chan interface{})
+	require.NoError(t, fb.GetAndWatch(map[string]int{}, values))
+	assert.Equal(t, map[string]int{"a": 1}, <-values)
+	assert.Equal(t, map[string]int{"a": 1, "b": 2}, <-values)
+	assert.Zero(t, atomic.LoadInt32(&gets), "the initial put is used as is")
+	fb.StopWatching()
+	for range values {
+	}
+}
+
+func TestWatchValueType(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: put\ndata: {\"path\":\"/\",\"data\":{\"a\":1}}\n\n")
+		fmt.Fprint(w, "event: put\ndata: {\"path\":\"/\",\"data\":null}\n\n")
+		w.(http.Flusher).Flush()
+		<-req.Context().Done()
+	}))
+	defer server.Close()
+
+	fb := New(server.URL, nil)
+	values := make(chan interface{})
+	require.NoError(t, fb.WatchValue(map[string]int{}, values))
+	assert.Equal(t, map[string]int{"a": 1}, <-values)
+	assert.Equal(t, map[string]int(nil), <-values)
+	fb.StopWatching()
+}
+
+func TestApplyEvent(t *testing.T) {
+	t.Parallel()
+	type m = map[string]interface{}
+	for _, test := range []struct {
+		name     string
+		events   []Event
+		expected interface{}
+	}{
+		{
+			name: "put replaces",
+			events: []Event{
+				{Type: EventTypePut, Path: "/", Data: m{"a": m{"x": 1.0, "y": 1.0}}},
+				{Type: EventTypePut, Path: "/a", Data: m{"z": 1.0}},
+			},
+			expected: m{"a": m{"z": 1.0}},
+		},
+		{
+			name: "put null deletes",
+			events: []Event{
+				{Type: EventTypePut, Path: "/", Data: m{"a": m{"x": 1.0}, "b": 1.0}},
+				{Type: EventTypePut, Path: "/a/x", Data: nil},
+			},
+			expected: m{"b": 1.0},
+		},
+		{
+			name: "put null at the root",
+			events: []Event{
+				{Type: EventTypePut, Path: "/", Data: m{"a": 1.0}},
+				{Type: EventTypePut, Path: "/", Data: nil},
+			},
+			expected: nil,
+		},
+		{
+			name: "put below a primitive",
+			events: []Event{
+				{Type: EventTypePut, Path: "/", Data: "foo"},
+				{Type: EventTypePut, Path: "/a/b", Data: 1.0},
+			},
+			expected: m{"a": m{"b": 1.0}},
+		},
+		{
+			name: "patch merges children",
+			events: []Event{
+				{Type: EventTypePut, Path: "/", Data: m{"a": 1.0, "b": 1.0}},
+				{Type: EventTypePatch, Path: "/", Data: m{"b": 2.0, "c": 2.0}},
+			},
+			expected: m{"a": 1.0, "b": 2.0, "c": 2.0},
+		},
+		{
+			name: "patch replaces nested children",
+			events: []Event{
+				{Type: EventTypePut, Path: "/", Data: m{"a": m{"x": 1.0, "y": 1.0}}},
+				{Type: EventTypePatch, Path: "/", Data: m{"a": m{"x": 2.0}}},
+			},
+			expected: m{"a": m{"x": 2.0}},
+		},
+		{
+			name: "nested patch",
+			events: []Event{
+				{Type: EventTypePut, Path: "/", Data: m{"a": m{"b": m{"x": 1.0, "y": 1.0}}}},
+				{Type: EventTypePatch, Path: "/a/b", Data: m{"y": 2.0}},
+				{Type: EventTypePatch, Path: "/a", Data: m{"b/z": 3.0, "c/d": 4.0}},
+			},
+			expected: m{"a": m{"b": m{"x": 1.0, "y": 2.0, "z": 3.0}, "c": m{"d": 4.0}}},
+		},
+		{
+			name: "patch deletions",
+			events: []Event{
+				{Type: EventTypePut, Path: "/", Data: m{"a": m{"b": m{"x": 1.0}}, "c": 1.0}},
+				{Type: EventTypePatch, Path: "/", Data: m{"a/b/x": nil, "c": nil, "d": nil}},
+			},
+			expected: nil,
+		},
+		{
+			name: "empty objects are deletions",
+			events: []Event{
+				{Type: EventTypePut, Path: "/", Data: m{"a": 1.0, "b": m{"c": 1.0}}},
+				{Type: EventTypePatch, Path: "/", Data: m{"b": m{}, "e": m{"f": nil}}},
+			},
+			expected: m{"a": 1.0},
+		},
+	} {
+		var data interface{}
+		for _, event := range test.events {
+			data = applyEvent(data, event)
+		}
+		assert.Equal(t, test.expected, data, test.name)
+	}
+}
+
+func TestSetPath(t *testing.T) {
+	t.Parallel()
+	var data interface{}
+	data = setPath(data, []string{"a", "b"}, 1.0)
+	data = setPath(data, []string{"a", "c"}, 2.0)
+	assert.Equal(t, map[string]interface{}{"a": map[string]interface{}{"b": 1.0, "c": 2.0}}, data)
+
+	data = setPath(data, []string{"a", "b"}, nil)
+	data = setPath(data, []string{"x"}, nil)
+	assert.Equal(t, map[string]interface{}{"a": map[string]interface{}{"c": 2.0}}, data)
+
+	data = setPath(data, []string{"a", "c"}, nil)
+	assert.Nil(t, data)
+
+	assert.Equal(t, "v", setPath(map[string]interface{}{"a": 1.0}, nil, "v"))
+}
+
+func TestWatchValue_StopWatching(t *testing.T) {
+	t.Parallel()
+	testStopWatchingForwarded(t, func(fb *Firebase) interface{} {
+		values := make(chan interface{})
+		require.NoError(t, fb.WatchValue(0, values))
+		return values
+	})
+}